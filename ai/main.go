@@ -26,99 +26,127 @@ const (
 
 var (
 	C       *chesspb.Client
-	Game    *chess.Chessboard
+	Game    *chess.Game
 	Playern int
 	Black   bool
-	MyTurn  bool
 	LastMove time.Time
 	DoingGuess bool
+	Listed  bool // asked the lobby for its challenges since last playing
+	Seeking bool // accepted or created a challenge, and waiting to be seated
 )
 
+// winReason describes how a game was won, if it wasn't by checkmate (ie: " by three checks").
+func winReason(r chesspb.GameComplete_Reason) string {
+	if r == chesspb.GameComplete_NoMoves {
+		return ""
+	}
+	return " by " + r.Describe()
+}
+
+// startingBoard returns the board a game starts from, fen being empty for the usual starting position.
+func startingBoard(fen string) *chess.Chessboard {
+	if fen == "" {
+		return chess.NewChessboard()
+	}
+	board, err := chess.ParseFEN(fen)
+	if err != nil {
+		log.Println("Bad starting position from server:", err)
+		return chess.NewChessboard()
+	}
+	return board
+}
+
 // FIXME AI will always promote queen
 
-func needsPromotion(x, y int8, board *chess.Chessboard) bool {
-	if (y == 7 || y == 0) && (board.Board[y][x] == chess.WhitePawn || board.Board[y][x] == chess.BlackPawn) {	
+func needsPromotion(p chess.Piece, y int8) bool {
+	if (y == 7 || y == 0) && (p == chess.WhitePawn || p == chess.BlackPawn) {
 		return true
 	}
 	return false
 }
 
-// doGuess makes a guess at the next best move from x, y, then returns the change in score.
-func doGuess(x, y int8, board *chess.Chessboard) (int, error) {
+// doGuess makes a guess at the next best move from x, y, then returns the change in score. If play is true the move
+// is sent to the server, otherwise it's made on board and returned, to be taken back with Unmake.
+func doGuess(x, y int8, board *chess.Chessboard, play bool) (chess.Move, int, error) {
 	if board.Board[y][x] == 0 {
 		log.Panicln("doGuess on empty space")
 	}
-	Moves, EnPassantKill, CanCastleLeft, CanCastleRight := board.PossibleMoves(x, y)
+	Moves, EnPassantKill, CanCastleLeft, CanCastleRight := board.PossibleMoves(chess.SquareAt(x, y))
 
 	var (
 		BestScore int
-		BestMoves, BestEnPassantKills [][2]int8
+		BestMoves, BestEnPassantKills []chess.Square
 		BestCastleLeft bool
 		BestCastleRight bool
 	)
 	BestScore = -500
 	
-	BestMoves = make([][2]int8, 0, 1)
-	BestEnPassantKills = make([][2]int8, 0, 1)
+	BestMoves = make([]chess.Square, 0, 1)
+	BestEnPassantKills = make([]chess.Square, 0, 1)
 	
 	black := chess.IsBlack(board.Board[y][x])
 	
 	theirScore := board.TotalValue(!black)
 	
 	for _, move := range Moves {
-		var totalScore int
-		tboard := new(chess.Chessboard)
-		*tboard = *board
-		val := chess.Value(tboard.Board[y][x])
-		tboard.DoMove([2]int8{x, y}, move) 
-		if needsPromotion(move[0], move[1], tboard) {
+		var (
+			totalScore int
+			promotion chess.Piece
+		)
+		val := chess.Value(board.Board[y][x])
+		if needsPromotion(board.Board[y][x], move.Y()) {
 			totalScore += 9
 			if black {
-				tboard.PromotePawn(move[0], move[1], chess.BlackQueen)
+				promotion = chess.BlackQueen
 			} else {
-				tboard.PromotePawn(move[0], move[1], chess.WhiteQueen)
+				promotion = chess.WhiteQueen
 			}
 		}
-		if tboard.IsCheckmated(!black) {
+		m := board.NewMove([2]int8{x, y}, move.Coords(), chess.RegularMove, promotion)
+		board.Make(m)
+		if board.IsCheckmate(!black) {
 			totalScore += 50
 		} else {
-			totalScore += theirScore-tboard.TotalValue(!black)
-			if tboard.Threat(!black)[move[1]][move[0]] {
+			totalScore += theirScore-board.TotalValue(!black)
+			if board.Threat(!black)[move] {
 				totalScore -= val
 			}
 		}
+		board.Unmake(m)
 		if totalScore == BestScore {
 			BestMoves = append(BestMoves, move)
 		} else if totalScore > BestScore {
-			BestMoves = [][2]int8{move}
+			BestMoves = []chess.Square{move}
 			BestScore = totalScore
 		}
 	}
 	for _, move := range EnPassantKill {
 		var totalScore int
-		tboard := *board
-		val := chess.Value(tboard.Board[y][x])
-		tboard.DoEnPassant([2]int8{x, y}, move)
-		if tboard.IsCheckmated(!black) {
+		val := chess.Value(board.Board[y][x])
+		m := board.NewMove([2]int8{x, y}, move.Coords(), chess.EnPassant, 0)
+		board.Make(m)
+		if board.IsCheckmate(!black) {
 			totalScore = 50
 		} else {
-			totalScore = theirScore-tboard.TotalValue(!black)
-			if tboard.Threat(!black)[move[1]][move[0]] {
+			totalScore = theirScore-board.TotalValue(!black)
+			if board.Threat(!black)[move] {
 				totalScore -= val
 			}
 		}
+		board.Unmake(m)
 		if totalScore == BestScore {
 			BestEnPassantKills = append(BestEnPassantKills, move)
 		} else if totalScore > BestScore {
 			BestMoves = nil
-			BestEnPassantKills = [][2]int8{move}
+			BestEnPassantKills = []chess.Square{move}
 			BestScore = totalScore
 		}
 	}
 	if CanCastleLeft {
-		tboard := *board
-		tboard.DoCastle([2]int8{x, y}, true)
-		totalScore := theirScore-tboard.TotalValue(!black)
+		m := board.NewMove([2]int8{x, y}, [2]int8{x, y}, chess.CastleLeft, 0)
+		board.Make(m)
+		totalScore := theirScore-board.TotalValue(!black)
+		board.Unmake(m)
 		if totalScore == BestScore {
 			BestCastleLeft = true
 		} else if totalScore > BestScore {
@@ -129,9 +157,10 @@ func doGuess(x, y int8, board *chess.Chessboard) (int, error) {
 		}
 	}
 	if CanCastleRight {
-		tboard := *board
-		tboard.DoCastle([2]int8{x, y}, false)
-		totalScore := theirScore-tboard.TotalValue(!black)
+		m := board.NewMove([2]int8{x, y}, [2]int8{x, y}, chess.CastleRight, 0)
+		board.Make(m)
+		totalScore := theirScore-board.TotalValue(!black)
+		board.Unmake(m)
 		if totalScore == BestScore {
 			BestCastleRight = true
 		} else if totalScore > BestScore {
@@ -144,57 +173,96 @@ func doGuess(x, y int8, board *chess.Chessboard) (int, error) {
 	}
 	
 	if len(BestMoves) == 0 && len(BestEnPassantKills) == 0 && !BestCastleLeft && !BestCastleRight {
-		if Game != nil && board == Game {
-			log.Println("board is Game, Game is not nil, but no best moves...")
+		if play {
+			log.Println("Playing, but no best moves...")
 		}
-		return 0, errors.New("No moves.")
+		return chess.Move{}, 0, errors.New("No moves.")
 	}
 	
 	for {
 		switch n := rand.Intn(len(BestMoves)+3); {
 			case n < len(BestMoves):
-				if board == Game {
+				if play {
 					log.Println("Move from: ", [2]int8{x, y}, ", to: ", BestMoves[n])
-					C.Send(&chesspb.Move{Fx: uint32(x), Fy: uint32(y), Tx: uint32(BestMoves[n][0]), Ty: uint32(BestMoves[n][1]), MoveType: chesspb.Move_MoveType(0)})
-				} else {
-					board.DoMove([2]int8{x, y}, BestMoves[n])
+					C.Send(chesspb.NewMove(chess.SquareAt(x, y), BestMoves[n], chess.RegularMove))
+					return chess.Move{}, BestScore, nil
 				}
-				return BestScore, nil
+				var promotion chess.Piece
+				if needsPromotion(board.Board[y][x], BestMoves[n].Y()) {
+					promotion = chess.WhiteQueen
+					if black {
+						promotion = chess.BlackQueen
+					}
+				}
+				m := board.NewMove([2]int8{x, y}, BestMoves[n].Coords(), chess.RegularMove, promotion)
+				board.Make(m)
+				return m, BestScore, nil
 			case n == len(BestMoves) && len(BestEnPassantKills) > 0:
-				if board == Game {
+				if play {
 					log.Println("En passant move from: ", [2]int8{x, y}, ", to: ", BestEnPassantKills[0])
-					C.Send(&chesspb.Move{Fx: uint32(x), Fy: uint32(y), Tx: uint32(BestEnPassantKills[0][0]), Ty: uint32(BestEnPassantKills[0][1]), MoveType: chesspb.Move_MoveType(1)})
-				} else {
-					board.DoEnPassant([2]int8{x, y}, BestEnPassantKills[0])
+					C.Send(chesspb.NewMove(chess.SquareAt(x, y), BestEnPassantKills[0], chess.EnPassant))
+					return chess.Move{}, BestScore, nil
 				}
-				return BestScore, nil
+				m := board.NewMove([2]int8{x, y}, BestEnPassantKills[0].Coords(), chess.EnPassant, 0)
+				board.Make(m)
+				return m, BestScore, nil
 			case n == len(BestMoves)+1 && BestCastleLeft:
-				if board == Game {
+				if play {
 					log.Println("Move from: ", [2]int8{x, y}, "castle left")
-					C.Send(&chesspb.Move{Fx: uint32(x), Fy: uint32(y), MoveType: chesspb.Move_MoveType(2)})
-				} else {
-					board.DoCastle([2]int8{x, y}, true)
+					C.Send(chesspb.NewMove(chess.SquareAt(x, y), chess.SquareAt(x, y), chess.CastleLeft))
+					return chess.Move{}, BestScore, nil
 				}
-				return BestScore, nil
+				m := board.NewMove([2]int8{x, y}, [2]int8{x, y}, chess.CastleLeft, 0)
+				board.Make(m)
+				return m, BestScore, nil
 			case n == len(BestMoves)+2 && BestCastleRight:
-				if board == Game {
+				if play {
 					log.Println("Move from: ", [2]int8{x, y}, "castle right")
-					C.Send(&chesspb.Move{Fx: uint32(x), Fy: uint32(y), MoveType: chesspb.Move_MoveType(2)})
-				} else {
-					board.DoCastle([2]int8{x, y}, false)
+					C.Send(chesspb.NewMove(chess.SquareAt(x, y), chess.SquareAt(x, y), chess.CastleRight))
+					return chess.Move{}, BestScore, nil
 				}
-				return BestScore, nil
+				m := board.NewMove([2]int8{x, y}, [2]int8{x, y}, chess.CastleRight, 0)
+				board.Make(m)
+				return m, BestScore, nil
+		}
+	}
+}
+
+// guessDrop picks a piece to drop from the pocket in Crazyhouse, onto a square the opponent doesn't threaten. It
+// prefers a drop which checkmates, then one which checks. Returns false if there's no such drop.
+func guessDrop(board *chess.Chessboard, black bool) (move chess.Move, mate bool, ok bool) {
+	best := -1
+	for _, m := range board.LegalMoves(black) {
+		if m.Type != chess.Drop {
+			continue
+		}
+		score := 0
+		check := board.Make(m)
+		if check && board.IsCheckmate(!black) {
+			score = 2
+		} else if board.Threat(!black)[chess.SquareAt(m.To[0], m.To[1])] {
+			score = -1
+		} else if check {
+			score = 1
+		}
+		board.Unmake(m)
+		if score > best {
+			move, best = m, score
 		}
 	}
+	return move, best == 2, best >= 0
 }
 
-// guessBestMove iterates over every possible move ahead times, and returns a single "best move" based on score.
-func guessBestMove(board *chess.Chessboard, ahead int, black bool) ([2]int8, int, error) {
+// guessBestMove iterates over every possible move ahead times, and returns a single "best move" based on score. root is
+// the position the search started from, which mustn't be board. Moves are tried with Make and taken back with Unmake, so
+// board is left as it was.
+func guessBestMove(root, board *chess.Chessboard, ahead int, black bool) ([2]int8, int, error) {
 	var (
 		BestScore int
 		BestMoves [][2]int8
 	)
 	BestScore = -5000
+	ourValue, theirValue := board.TotalValue(black), board.TotalValue(!black) // before any move is tried
 	
 	for y, row := range board.Board {
 		for x, p := range row {
@@ -203,28 +271,28 @@ func guessBestMove(board *chess.Chessboard, ahead int, black bool) ([2]int8, int
 			}
 			var (
 				Score, EnemyScore int
-				err error
 				move [2]int8
+				enemyMove *chess.Move
 			)
-			tboard := new(chess.Chessboard)
-			*tboard = *board
-			_, err = doGuess(int8(x), int8(y), tboard)
+			ourMove, _, err := doGuess(int8(x), int8(y), board, false)
 			if err != nil { // we can't move this piece
 				continue
 			}
-			Score = tboard.TotalValue(black) - Game.TotalValue(black) - 10
-			EnemyScore = tboard.TotalValue(!black) - Game.TotalValue(!black)
+			Score = board.TotalValue(black) - root.TotalValue(black) - 10
+			EnemyScore = board.TotalValue(!black) - root.TotalValue(!black)
 			if (Score != -10 || EnemyScore != 0) && ahead == LOOKAHEAD {
 				log.Println("Best score:", BestScore, "Score:", Score, "Enemy Score:", EnemyScore)
 				log.Println("Move from:", x, y)
 			}
 			move = [2]int8{int8(x), int8(y)}
 			if ahead > 0 {
-				enemymove, enemyBestScore, err := guessBestMove(tboard, ahead-1, !black)
+				enemymove, enemyBestScore, err := guessBestMove(root, board, ahead-1, !black)
 				if err == nil {
-					_, err = doGuess(enemymove[0], enemymove[1], tboard)
+					var m chess.Move
+					m, _, err = doGuess(enemymove[0], enemymove[1], board, false)
 					if err == nil {
-						Score = (tboard.TotalValue(black) - board.TotalValue(black))*ahead
+						enemyMove = &m
+						Score = (board.TotalValue(black) - ourValue)*ahead
 						Score -= enemyBestScore
 					} else {
 						log.Println("doGuess has no moves.")
@@ -234,21 +302,25 @@ func guessBestMove(board *chess.Chessboard, ahead int, black bool) ([2]int8, int
 					log.Println("guessBestMove has no moves.")
 					Score *= ahead
 				}
-				Score = Score - (tboard.TotalValue(!black) - board.TotalValue(!black))*ahead
+				Score = Score - (board.TotalValue(!black) - theirValue)*ahead
 			} else {
 				Score -= EnemyScore
 			}
-			if tboard.IsCheckmated(!black) {
+			if board.IsCheckmate(!black) {
 				/*if black == Black {
 					log.Println("Possible win detected...")
 				}*/
 				Score = 50*(ahead+1)
-			} else if tboard.IsCheckmated(black) {
+			} else if board.IsCheckmate(black) {
 				/*if black == Black {
 					log.Println("Possible loss detected...")
 				}*/
 				Score = -50*(ahead+1)
 			}
+			if enemyMove != nil {
+				board.Unmake(*enemyMove)
+			}
+			board.Unmake(ourMove)
 			
 			if Score == BestScore {
 				BestMoves = append(BestMoves, move)
@@ -295,19 +367,26 @@ func connect() error {
 	for {
 		last := time.Now()
 
-		if Playern == 0 {
-			C.Send(&chesspb.Join{Player: true})
+		if Playern == 0 && !Listed {
+			C.Send(new(chesspb.ListGames)) // accept an open challenge, or create one, once the lobby replies
+			Listed = true
 		}
-		if Game != nil && MyTurn && time.Since(LastMove) >= time.Millisecond * 75 && !DoingGuess {
+		if Game != nil && Game.BlackMove == Black && time.Since(LastMove) >= time.Millisecond * 75 && !DoingGuess {
 				DoingGuess = true
+				board := Game.Copy() // search a copy, the read loop keeps applying moves to Game
 				go func() {
 					log.Println("Guess begin...")
-					guess, _, err := guessBestMove(Game, LOOKAHEAD, Black)
-					if err != nil {
+					guess, _, err := guessBestMove(board.Copy(), board, LOOKAHEAD, Black)
+					if drop, mate, ok := guessDrop(board, Black); ok && (mate || err != nil || rand.Intn(3) == 0) {
+						log.Println("Drop:", drop)
+						m := chesspb.NewMove(chess.NoSquare, chess.SquareAt(drop.To[0], drop.To[1]), chess.Drop)
+						m.Piece = int32(drop.Dropped)
+						C.Send(m)
+					} else if err != nil {
 						log.Println(err)
 					} else {
 						log.Println("Piece to move:", guess)
-						doGuess(guess[0], guess[1], Game)
+						doGuess(guess[0], guess[1], board, true)
 						log.Println("Guessed.")
 					}
 					LastMove = time.Now()
@@ -341,7 +420,19 @@ func connect() error {
 		case *chesspb.OpponentJoined:
 			log.Println("Opponent joined, game is ready to begin!")
 			C.Send(new(chesspb.NewGame))
+		case *chesspb.ChallengeList:
+			if Playern != 0 || Seeking {
+				continue
+			}
+			Seeking = true
+			if len(v.Challenges) > 0 {
+				log.Println("Accepting challenge:", v.Challenges[0].Describe())
+				C.Send(&chesspb.AcceptChallenge{Game: v.Challenges[0].Game})
+			} else {
+				C.Send(new(chesspb.CreateChallenge)) // wait in the lobby for someone to accept
+			}
 		case *chesspb.Player:
+			Seeking = false
 			if v.One {
 				if DEBUG {
 					log.Println("Joined as player 1.")
@@ -357,45 +448,55 @@ func connect() error {
 			if v.Black {
 				Black = true
 				log.Println("Assigned to black.")
-				MyTurn = false
 			} else {
 				Black = false
 				log.Println("Assigned to white.")
-				MyTurn = true
 			}
-			Game = chess.NewChessboard()
+			board := startingBoard(v.Fen)
+			board.Variant = v.Variant.Chess()
+			Game = chess.NewGame(board)
 		case *chesspb.Move:
+			from, to := v.From(), v.To()
 			switch chess.MoveType(v.MoveType) {
 			case chess.RegularMove:
-				Game.DoMove([2]int8{int8(v.Fx), int8(v.Fy)}, [2]int8{int8(v.Tx), int8(v.Ty)})
+				Game.DoMove(from.Coords(), to.Coords())
 			case chess.EnPassant:
-				Game.DoEnPassant([2]int8{int8(v.Fx), int8(v.Fy)}, [2]int8{int8(v.Tx), int8(v.Ty)})
+				Game.DoEnPassant(from.Coords(), to.Coords())
 			case chess.CastleLeft:
-				Game.DoCastle([2]int8{int8(v.Fx), int8(v.Fy)}, true)
+				Game.DoCastle(from.Coords(), true)
 			case chess.CastleRight:
-				Game.DoCastle([2]int8{int8(v.Fx), int8(v.Fy)}, false)
+				Game.DoCastle(from.Coords(), false)
+			case chess.Drop:
+				Game.DoDrop(chess.Piece(v.Piece), to.Coords())
 			}
 			DoingGuess = false
-			MyTurn = !MyTurn
 			time.Sleep(50*time.Millisecond)
 		case *chesspb.GameComplete:
 			switch v.Result {
 			case chesspb.GameComplete_Stalemate:
 				log.Println("Game complete! Stalemate.")
 			case chesspb.GameComplete_WhiteWin:
-				log.Println("Game complete! White wins!")
+				log.Println("Game complete! White wins" + winReason(v.Reason) + "!")
 			case chesspb.GameComplete_BlackWin:
-				log.Println("Game complete! Black wins!")
+				log.Println("Game complete! Black wins" + winReason(v.Reason) + "!")
+			case chesspb.GameComplete_Draw:
+				log.Println("Game complete! Draw by " + v.Reason.Describe() + ".")
 			}
 			// TODO STORE DATA IN DB.
 			Game = nil
 			Playern = 0
+			Listed = false
 		case *chesspb.OpponentLeft:
 			log.Println("Opponent left, need to rejoin.")
 			Game = nil
 			Playern = 0
+			Listed = false
 		case *chesspb.Error:
 			log.Println("Server error: " + v.Msg)
+			if Seeking { // the challenge was taken by someone else, look again
+				Seeking = false
+				Listed = false
+			}
 		}
 	}
 	return nil
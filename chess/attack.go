@@ -0,0 +1,122 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "math/bits"
+
+// squares returns the squares set in a bitboard, in order.
+func squares(b uint64) (s []Square) {
+	for ; b != 0; b &= b - 1 {
+		s = append(s, Square(bits.TrailingZeros64(b)))
+	}
+	return
+}
+
+// between returns the squares strictly between a and b if they share a rank, file or diagonal, otherwise 0.
+func between(a, b int) uint64 {
+	if rookAttacks(a, 0)&(1<<uint(b)) != 0 {
+		return rookAttacks(a, 1<<uint(b)) & rookAttacks(b, 1<<uint(a))
+	}
+	if bishopAttacks(a, 0)&(1<<uint(b)) != 0 {
+		return bishopAttacks(a, 1<<uint(b)) & bishopAttacks(b, 1<<uint(a))
+	}
+	return 0
+}
+
+// Attackers returns the squares of the colour's pieces which attack s, whether or not they could legally move there.
+func (cb *Chessboard) Attackers(s Square, black bool) []Square {
+	if !s.Valid() {
+		return nil
+	}
+	return squares(cb.attackers(int(s), black, cb.occupied()))
+}
+
+// Pinned returns the squares of the colour's pieces which can't leave the line between their king and an enemy
+// rook, bishop or queen without exposing the king. A colour without a king has no pinned pieces.
+func (cb *Chessboard) Pinned(black bool) []Square {
+	return squares(cb.pinned(black))
+}
+
+// pinned returns the colour's pieces which are pinned to their king.
+func (cb *Chessboard) pinned(black bool) (pinned uint64) {
+	king := cb.pieces[colour(black)*6+kingKind]
+	if king == 0 {
+		return 0
+	}
+	sq, e := bits.TrailingZeros64(king), (1-colour(black))*6
+	occupied := cb.occupied()
+	snipers := rookAttacks(sq, 0)&(cb.pieces[e+rookKind]|cb.pieces[e+queenKind]) |
+		bishopAttacks(sq, 0)&(cb.pieces[e+bishopKind]|cb.pieces[e+queenKind])
+	for ; snipers != 0; snipers &= snipers - 1 {
+		blockers := between(sq, bits.TrailingZeros64(snipers)) & occupied
+		if bits.OnesCount64(blockers) == 1 {
+			pinned |= blockers & cb.colours[colour(black)]
+		}
+	}
+	return
+}
+
+// Checkers returns the squares of the pieces giving check to the side to move.
+func (cb *Chessboard) Checkers() []Square {
+	black := cb.BlackMove
+	king := cb.pieces[colour(black)*6+kingKind]
+	if king == 0 || (cb.Variant != nil && !cb.IsCheck(black)) {
+		return nil
+	}
+	return squares(cb.attackers(bits.TrailingZeros64(king), !black, cb.occupied()))
+}
+
+// seeValue is a piece's value for SEE, where a king is worth more than everything else put together.
+func seeValue(p Piece) int {
+	if p == WhiteKing || p == BlackKing {
+		return 100
+	}
+	return Value(p)
+}
+
+// leastValuable returns the least valuable of the colour's pieces in attackers, and its bitboard, or 0 if there
+// isn't one.
+func (cb *Chessboard) leastValuable(attackers uint64, black bool) (Piece, uint64) {
+	c := colour(black)
+	for _, kind := range [6]int{pawnKind, knightKind, bishopKind, rookKind, queenKind, kingKind} {
+		if b := attackers & cb.pieces[c*6+kind]; b != 0 {
+			return WhiteKing + Piece(c*6+kind), b & -b
+		}
+	}
+	return 0, 0
+}
+
+// SEE (static exchange evaluation) returns the material the colour can expect to win by capturing the piece on s,
+// with each side taking back with its least valuable attacker for as long as it gains by doing so. It's 0 if there's
+// nothing of the other colour to take, or nothing to take it with. Pins, promotions and en passant are ignored.
+func (cb *Chessboard) SEE(s Square, black bool) int {
+	if !s.Valid() {
+		return 0
+	}
+	target := cb.Board[s.Y()][s.X()]
+	if !isPiece(target) || IsBlack(target) == black {
+		return 0
+	}
+	sq, occupied := int(s), cb.occupied()
+	var gain [32]int
+	gain[0] = seeValue(target)
+	piece, from := cb.leastValuable(cb.attackers(sq, black, occupied), black)
+	if from == 0 {
+		return 0
+	}
+	d := 0
+	for from != 0 && d < len(gain)-1 {
+		d++
+		// what the side capturing now gains if its piece is taken back
+		gain[d] = seeValue(piece) - gain[d-1]
+		occupied &^= from
+		black = !black
+		piece, from = cb.leastValuable(cb.attackers(sq, black, occupied)&occupied, black)
+	}
+	for d--; d > 0; d-- {
+		if -gain[d-1] < gain[d] {
+			gain[d-1] = -gain[d]
+		}
+	}
+	return gain[0]
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAttackers(t *testing.T) {
+	cb, err := ParseFEN(kiwipete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		square string
+		black  bool
+		want   string
+	}{
+		{"f6", false, "[f3]"},
+		{"d5", true, "[b6 e6 f6]"},
+		{"a1", true, "[]"},
+	} {
+		s, _ := ParseSquare(test.square)
+		if got := fmt.Sprint(cb.Attackers(s, test.black)); got != test.want {
+			t.Errorf("Attackers(%s, %v) = %s, want %s", test.square, test.black, got, test.want)
+		}
+	}
+}
+
+func TestPinnedAndCheckers(t *testing.T) {
+	for _, test := range []struct {
+		fen             string
+		pinned, checker string
+	}{
+		{"4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1", "[e2]", "[]"},
+		{"4k3/8/8/b7/8/2P5/3P4/4K3 w - - 0 1", "[]", "[]"},
+		{"4k3/8/8/b7/8/8/3P4/3RK3 w - - 0 1", "[d2]", "[]"},
+		{"4k3/4r3/8/8/4n3/8/4N3/4K3 w - - 0 1", "[]", "[]"},
+		{"4k3/8/8/8/1b6/8/3N4/r3K3 w - - 0 1", "[d2]", "[a1]"},
+		{"4k3/8/8/8/8/3n4/8/4K3 w - - 0 1", "[]", "[d3]"},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(cb.Pinned(false)); got != test.pinned {
+			t.Errorf("%s: Pinned() = %s, want %s", test.fen, got, test.pinned)
+		}
+		if got := fmt.Sprint(cb.Checkers()); got != test.checker {
+			t.Errorf("%s: Checkers() = %s, want %s", test.fen, got, test.checker)
+		}
+	}
+
+	// kings next to each other can't be in check in Atomic
+	cb, err := ParseFEN("8/8/8/8/8/3kK3/8/7r w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.Variant = Atomic{}
+	if checkers := cb.Checkers(); len(checkers) != 0 {
+		t.Errorf("Checkers() = %v in Atomic with the kings touching", checkers)
+	}
+}
+
+func TestSEE(t *testing.T) {
+	for _, test := range []struct {
+		fen    string
+		square string
+		want   int
+	}{
+		{"4k3/8/8/3p4/8/8/8/3QK3 w - - 0 1", "d5", 1},       // undefended pawn
+		{"4k3/8/4p3/3p4/8/8/8/3QK3 w - - 0 1", "d5", -8},    // queen takes a defended pawn
+		{"4k3/8/4p3/3n4/8/8/8/3RK3 w - - 0 1", "d5", -2},    // rook takes a knight defended by a pawn
+		{"3rk3/8/8/3n4/8/8/3R4/3QK3 w - - 0 1", "d5", 3},    // queen backs up the rook
+		{"3rk3/3q4/8/3n4/8/8/3R4/3QK3 w - - 0 1", "d5", -2}, // the queen in front of the rook recaptures
+		{"4k3/8/4p3/3q4/2P5/8/8/4K3 w - - 0 1", "d5", 8},    // pawn takes a defended queen
+		{"4k3/8/8/3p4/8/8/8/4K3 w - - 0 1", "d5", 0},        // nothing to take with
+		{"4k3/8/8/3P4/8/8/8/3QK3 w - - 0 1", "d5", 0},       // own piece
+		{"4k3/8/8/8/8/8/3p4/4K3 w - - 0 1", "d2", 1},        // king takes an undefended pawn
+		{"4k3/8/8/8/2n5/8/3p4/4K3 w - - 0 1", "d2", -99},    // the first capture is always made
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, _ := ParseSquare(test.square)
+		if got := cb.SEE(s, false); got != test.want {
+			t.Errorf("%s: SEE(%s) = %d, want %d", test.fen, test.square, got, test.want)
+		}
+	}
+}
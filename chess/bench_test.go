@@ -0,0 +1,61 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+// kiwipete is a busy middlegame position, with castling, en passant and promotions all possible within a few moves.
+const kiwipete = "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"
+
+func benchmarkBoard(b *testing.B) *Chessboard {
+	cb, err := ParseFEN(kiwipete)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	return cb
+}
+
+func BenchmarkPerft(b *testing.B) {
+	cb := benchmarkBoard(b)
+	for i := 0; i < b.N; i++ {
+		cb.Perft(2)
+	}
+}
+
+func BenchmarkLegalMoves(b *testing.B) {
+	cb := benchmarkBoard(b)
+	for i := 0; i < b.N; i++ {
+		cb.LegalMoves(false)
+	}
+}
+
+func BenchmarkPossibleMoves(b *testing.B) {
+	cb := benchmarkBoard(b)
+	for i := 0; i < b.N; i++ {
+		cb.PossibleMoves(SquareAt(5, 5)) // white queen on f3
+	}
+}
+
+func BenchmarkThreat(b *testing.B) {
+	cb := benchmarkBoard(b)
+	for i := 0; i < b.N; i++ {
+		cb.Threat(true)
+	}
+}
+
+func BenchmarkIsCheck(b *testing.B) {
+	cb := benchmarkBoard(b)
+	for i := 0; i < b.N; i++ {
+		cb.IsCheck(false)
+	}
+}
+
+func BenchmarkAppendLegalMoves(b *testing.B) {
+	cb := benchmarkBoard(b)
+	moves := make([]Move, 0, maxMoves)
+	for i := 0; i < b.N; i++ {
+		moves = cb.AppendLegalMoves(moves[:0], false)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "math/bits"
+
+// Alongside Board, a Chessboard keeps a bitboard for each piece and colour: a uint64 with a bit set for every square
+// the piece occupies. Squares are numbered y*8+x, so a8 is 0 and h1 is 63. Sliding attacks are looked up with magic
+// bitboards.
+
+// Piece kinds, in the order of the pieces' runes. A piece's index in Chessboard.pieces is its kind, plus 6 if it's
+// black.
+const (
+	kingKind = iota
+	queenKind
+	rookKind
+	bishopKind
+	knightKind
+	pawnKind
+)
+
+// maxMoves is enough room for every legal move in any position.
+const maxMoves = 256
+
+// isPiece returns true if p is one of the 12 pieces.
+func isPiece(p Piece) bool {
+	return p >= WhiteKing && p <= BlackPawn
+}
+
+// pieceIndex returns the index of a piece in Chessboard.pieces. p must be a piece.
+func pieceIndex(p Piece) int {
+	return int(p - WhiteKing)
+}
+
+// colour returns the index of a colour in Chessboard.colours.
+func colour(black bool) int {
+	if black {
+		return 1
+	}
+	return 0
+}
+
+// square returns the number of the square at x, y.
+func square(x, y int8) int {
+	return int(y)*8 + int(x)
+}
+
+// bit returns a bitboard with only the square at x, y set.
+func bit(x, y int8) uint64 {
+	return 1 << uint(square(x, y))
+}
+
+var (
+	knightAttacks, kingAttacks [64]uint64
+	pawnAttacks                [2][64]uint64 // squares a white or black pawn attacks
+
+	rookMasks, bishopMasks             [64]uint64 // squares which can block a slider, board edges excluded
+	rookShifts, bishopShifts           [64]uint
+	rookAttackTable, bishopAttackTable [64][]uint64 // indexed by the magic hash of the blocking squares
+
+	// CanBeEnPassant points into enPassantSquares, so Make doesn't need to allocate. It's never modified.
+	enPassantSquares [8][8][2]int8
+)
+
+var (
+	rookDirections   = [4][2]int8{{0, -1}, {0, 1}, {-1, 0}, {1, 0}}
+	bishopDirections = [4][2]int8{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
+)
+
+// slide returns the squares a slider on sq attacks, stopping at (and including) the first occupied square in each
+// direction. It's only used to build the lookup tables.
+func slide(sq int, occupied uint64, directions [4][2]int8) (attacks uint64) {
+	for _, d := range directions {
+		x, y := int8(sq&7)+d[0], int8(sq>>3)+d[1]
+		for ; x >= 0 && x < 8 && y >= 0 && y < 8; x, y = x+d[0], y+d[1] {
+			attacks |= bit(x, y)
+			if occupied&bit(x, y) != 0 {
+				break
+			}
+		}
+	}
+	return
+}
+
+// slideMask returns the squares which can block a slider on sq. The last square in each direction is left out, as a
+// piece there doesn't block anything.
+func slideMask(sq int, directions [4][2]int8) (mask uint64) {
+	for _, d := range directions {
+		x, y := int8(sq&7)+d[0], int8(sq>>3)+d[1]
+		for ; x+d[0] >= 0 && x+d[0] < 8 && y+d[1] >= 0 && y+d[1] < 8; x, y = x+d[0], y+d[1] {
+			mask |= bit(x, y)
+		}
+	}
+	return
+}
+
+// fillAttackTable fills a slider's attack table for sq with every arrangement of blockers.
+func fillAttackTable(sq int, mask, magic uint64, shift uint, directions [4][2]int8) []uint64 {
+	table := make([]uint64, 1<<(64-shift))
+	// enumerate every subset of mask
+	for blockers := uint64(0); ; blockers = (blockers - mask) & mask {
+		table[(blockers*magic)>>shift] = slide(sq, blockers, directions)
+		if blockers == mask {
+			break
+		}
+	}
+	return table
+}
+
+func init() {
+	for y := int8(0); y < 8; y++ {
+		for x := int8(0); x < 8; x++ {
+			sq := square(x, y)
+			enPassantSquares[y][x] = [2]int8{x, y}
+			for _, d := range [...][2]int8{{-1, -2}, {1, -2}, {2, -1}, {2, 1}, {1, 2}, {-1, 2}, {-2, 1}, {-2, -1}} {
+				if tx, ty := x+d[0], y+d[1]; tx >= 0 && tx < 8 && ty >= 0 && ty < 8 {
+					knightAttacks[sq] |= bit(tx, ty)
+				}
+			}
+			for _, d := range [...][2]int8{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}} {
+				if tx, ty := x+d[0], y+d[1]; tx >= 0 && tx < 8 && ty >= 0 && ty < 8 {
+					kingAttacks[sq] |= bit(tx, ty)
+				}
+			}
+			for _, tx := range [2]int8{x - 1, x + 1} {
+				if tx < 0 || tx > 7 {
+					continue
+				}
+				if y > 0 {
+					pawnAttacks[0][sq] |= bit(tx, y-1)
+				}
+				if y < 7 {
+					pawnAttacks[1][sq] |= bit(tx, y+1)
+				}
+			}
+
+			rookMasks[sq] = slideMask(sq, rookDirections)
+			rookShifts[sq] = uint(64 - bits.OnesCount64(rookMasks[sq]))
+			rookAttackTable[sq] = fillAttackTable(sq, rookMasks[sq], rookMagics[sq], rookShifts[sq], rookDirections)
+			bishopMasks[sq] = slideMask(sq, bishopDirections)
+			bishopShifts[sq] = uint(64 - bits.OnesCount64(bishopMasks[sq]))
+			bishopAttackTable[sq] = fillAttackTable(sq, bishopMasks[sq], bishopMagics[sq], bishopShifts[sq], bishopDirections)
+		}
+	}
+}
+
+// rookAttacks returns the squares a rook on sq attacks.
+func rookAttacks(sq int, occupied uint64) uint64 {
+	return rookAttackTable[sq][((occupied&rookMasks[sq])*rookMagics[sq])>>rookShifts[sq]]
+}
+
+// bishopAttacks returns the squares a bishop on sq attacks.
+func bishopAttacks(sq int, occupied uint64) uint64 {
+	return bishopAttackTable[sq][((occupied&bishopMasks[sq])*bishopMagics[sq])>>bishopShifts[sq]]
+}
+
+// occupied returns every occupied square.
+func (cb *Chessboard) occupied() uint64 {
+	return cb.colours[0] | cb.colours[1]
+}
+
+// attacks returns the squares the piece with index i on sq attacks, given the occupied squares.
+func attacks(i, sq int, occupied uint64) uint64 {
+	switch i % 6 {
+	case kingKind:
+		return kingAttacks[sq]
+	case queenKind:
+		return rookAttacks(sq, occupied) | bishopAttacks(sq, occupied)
+	case rookKind:
+		return rookAttacks(sq, occupied)
+	case bishopKind:
+		return bishopAttacks(sq, occupied)
+	case knightKind:
+		return knightAttacks[sq]
+	}
+	return pawnAttacks[i/6][sq]
+}
+
+// attackers returns the pieces of the specified colour which attack sq, given the occupied squares. Pieces which
+// aren't in occupied are still included, so callers removing a piece from the board should mask it out.
+func (cb *Chessboard) attackers(sq int, black bool, occupied uint64) uint64 {
+	c := colour(black)
+	pieces := cb.pieces[c*6 : c*6+6]
+	return pawnAttacks[1-c][sq]&pieces[pawnKind] |
+		knightAttacks[sq]&pieces[knightKind] |
+		kingAttacks[sq]&pieces[kingKind] |
+		rookAttacks(sq, occupied)&(pieces[rookKind]|pieces[queenKind]) |
+		bishopAttacks(sq, occupied)&(pieces[bishopKind]|pieces[queenKind])
+}
+
+// kingSafe returns true if the colour's king isn't attacked once a piece moves from from to to, taking whatever is
+// on captured. A colour without a king is always safe.
+func (cb *Chessboard) kingSafe(from, to, captured uint64, black bool) bool {
+	king := cb.pieces[colour(black)*6+kingKind]
+	if king == 0 {
+		return true
+	}
+	if king&from != 0 {
+		king = to
+	}
+	occupied := cb.occupied()&^from&^captured | to
+	return cb.attackers(bits.TrailingZeros64(king), !black, occupied)&^captured == 0
+}
+
+// set places p (or nothing, if p is 0) on x, y, keeping the bitboards and hash up to date.
+func (cb *Chessboard) set(x, y int8, p Piece) {
+	sq, b := square(x, y), bit(x, y)
+	if old := cb.Board[y][x]; isPiece(old) {
+		i := pieceIndex(old)
+		cb.pieces[i] &^= b
+		cb.colours[i/6] &^= b
+		cb.hash ^= zobristPieces[i][sq]
+	}
+	if isPiece(p) {
+		i := pieceIndex(p)
+		cb.pieces[i] |= b
+		cb.colours[i/6] |= b
+		cb.hash ^= zobristPieces[i][sq]
+	}
+	cb.Board[y][x] = p
+}
+
+// Sync recomputes the bitboards and hash from Board and the other fields. It must be called after changing them
+// directly, rather than through the Chessboard's methods.
+func (cb *Chessboard) Sync() {
+	cb.pieces, cb.colours = [12]uint64{}, [2]uint64{}
+	cb.hash = cb.stateKey()
+	for y := range cb.Board {
+		for x, p := range cb.Board[y] {
+			if !isPiece(p) {
+				continue
+			}
+			sq, i := square(int8(x), int8(y)), pieceIndex(p)
+			cb.pieces[i] |= 1 << uint(sq)
+			cb.colours[i/6] |= 1 << uint(sq)
+			cb.hash ^= zobristPieces[i][sq]
+		}
+	}
+	if len(cb.history) > 0 {
+		cb.history[len(cb.history)-1] = cb.hash
+	}
+}
+
+// rookMagics and bishopMagics hash the blocking squares of a slider into its attack table. They were found by a
+// random search, TestMagics checks them.
+var rookMagics = [64]uint64{
+	0x1080004008801020, 0x0840092002C03000, 0x1900200010400900, 0x0880100008000480,
+	0x4200100420080200, 0x8100020100080400, 0x0200040110886200, 0x0200008040220411,
+	0x0404800084400220, 0x0000401000402000, 0x0086001081220440, 0x0408800800100280,
+	0x000A001201040820, 0x8848800200840080, 0x4001000100040200, 0x0442000102105084,
+	0x9080010020804100, 0x0040404000201009, 0x0000808010002009, 0x2200090021D00100,
+	0x0008008008040080, 0x0004004002010040, 0x0011040008015042, 0x00000A0001768104,
+	0x0000800080204009, 0x2010004140002001, 0x9800200280100080, 0x1000100080080080,
+	0x0442000A00049020, 0x2100040080020080, 0x0800120400900148, 0x0010040A00128541,
+	0x2800804000800030, 0x1010002000400041, 0x4000200011004100, 0x0610008410800800,
+	0x0400802402800800, 0xC100020080800400, 0x0002000802000401, 0x0182085882000401,
+	0x0220204000808000, 0x2860100040024022, 0x0001002004110040, 0x99101042000A0020,
+	0x0004080004008080, 0x0010040002008080, 0x2012004881020004, 0x8300842444820011,
+	0x0088403882010200, 0x0820400080210100, 0x0110910040A00300, 0x0801100280080480,
+	0x0242009008200600, 0x1002000489500200, 0x0040800200010080, 0x0091800041000080,
+	0x0000209300488001, 0x04C1002414824001, 0x020020000B001041, 0x7000100004200901,
+	0x8002002004100802, 0x30010002084C0007, 0x0888221800813004, 0x4000002840840112,
+}
+var bishopMagics = [64]uint64{
+	0xA010041108003100, 0x006082020A002900, 0x6810010619200000, 0x08281A0520000408,
+	0x0001104001000400, 0x0018901008048400, 0x00040A0210245280, 0x000200210808A402,
+	0x9140048410821200, 0x0800091010820041, 0x20504804832202C0, 0x0100091401081000,
+	0x8021011140000012, 0x0810020804450400, 0x208B0542109008A2, 0x0080084A08040204,
+	0x0040E2A80811244C, 0x2505022008008108, 0x0430220100420040, 0x010A040420220040,
+	0x1105000290400000, 0x0093001200822120, 0x4000A62048043004, 0x280120048A015004,
+	0x006090002A020814, 0x44042000240800D0, 0x01102800040A4400, 0x1004080080220040,
+	0x0001001011004024, 0x0010044000805040, 0x0914041200820100, 0x0004821012821480,
+	0x0024040500C05021, 0x0088611002080200, 0x0116080A00040020, 0x4000020080080080,
+	0x2450450140840040, 0x0000880201484100, 0x0222020404020092, 0x8081110600002E00,
+	0x2842101105000801, 0x1100809008001025, 0x00020202221C0400, 0x0422014022009020,
+	0x0210046102100C00, 0xC004008082029102, 0x00AA461801101200, 0x0404080080201108,
+	0x020542108C205002, 0x0410544804100100, 0x0040910841100000, 0x0400200042021100,
+	0x00004204850400C0, 0x0200100410A42102, 0x1040020801210102, 0x0805040410420000,
+	0x2884804130100200, 0x800C262201242000, 0x1058000194108800, 0x0014221054420204,
+	0x0104000012A02200, 0x0200881003300100, 0x0140400202840100, 0x0402020801010201,
+}
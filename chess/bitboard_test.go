@@ -0,0 +1,74 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMagics(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for sq := 0; sq < 64; sq++ {
+		for i := 0; i < 1000; i++ {
+			occupied := r.Uint64() & r.Uint64()
+			if got, want := rookAttacks(sq, occupied), slide(sq, occupied, rookDirections); got != want {
+				t.Fatalf("rook on %d with %016x occupied: got %016x, want %016x", sq, occupied, got, want)
+			}
+			if got, want := bishopAttacks(sq, occupied), slide(sq, occupied, bishopDirections); got != want {
+				t.Fatalf("bishop on %d with %016x occupied: got %016x, want %016x", sq, occupied, got, want)
+			}
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	cb, err := ParseFEN(kiwipete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces, colours, hash := cb.pieces, cb.colours, cb.hash
+	cb.Board[7][4], cb.Board[5][5] = 0, WhiteKing // move the king directly
+	cb.Sync()
+	if cb.Hash() == hash {
+		t.Error("hash unchanged after moving the king")
+	}
+	cb.Board[7][4], cb.Board[5][5] = WhiteKing, WhiteQueen
+	cb.Sync()
+	if cb.pieces != pieces || cb.colours != colours || cb.hash != hash {
+		t.Error("bitboards or hash differ after restoring the position")
+	}
+}
+
+func TestSyncLegalMoves(t *testing.T) {
+	cb := NewChessboard()
+	cb.Board[6][4], cb.Board[5][4] = 0, BlackRook // take away the e2 pawn, and check the king from e3
+	cb.Sync()
+	var moves []string
+	for _, m := range cb.LegalMoves(false) {
+		moves = append(moves, m.String())
+	}
+	sort.Strings(moves)
+	if want := []string{"d1e2", "d2e3", "f1e2", "f2e3", "g1e2"}; !reflect.DeepEqual(moves, want) {
+		t.Errorf("LegalMoves() after Sync = %v, want %v", moves, want)
+	}
+}
+
+func TestAppendLegalMovesAllocs(t *testing.T) {
+	cb, err := ParseFEN(kiwipete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]Move, 0, maxMoves)
+	if allocs := testing.AllocsPerRun(100, func() {
+		for _, m := range cb.AppendLegalMoves(buf[:0], cb.BlackMove) {
+			cb.Make(m)
+			cb.Threat(cb.BlackMove)
+			cb.Unmake(m)
+		}
+	}); allocs != 0 {
+		t.Errorf("got %v allocations, want 0", allocs)
+	}
+}
@@ -2,6 +2,8 @@
 
 package chess
 
+import "math/bits"
+
 type Piece rune
 
 const (
@@ -44,13 +46,29 @@ func IsBlack(p Piece) bool {
 }
 
 type Chessboard struct {
+	// Board holds the piece on each square, indexed [y][x] from a8. Moves are generated from bitboards kept alongside
+	// it, so after changing it (or the fields below) directly, Sync must be called before making or generating moves.
 	Board                                                                                [8][8]Piece
 	WhiteCantCastleLeft, WhiteCantCastleRight, BlackCantCastleLeft, BlackCantCastleRight bool
 	CanBeEnPassant                                                                       *[2]int8
+	HalfMoveClock                                                                        int        // moves since the last capture or pawn move
+	FullMoveNumber                                                                       int        // starts at 1, incremented after black moves
+	BlackMove                                                                            bool       // true if it's black's move
+	Chess960                                                                             bool       // if true, castling uses the rooks on RookFiles
+	RookFiles                                                                            [2][2]int8 // for Chess960, files of the rooks white then black castle with, left then right
+	Variant                                                                              Variant    // rules being played, nil for standard chess
+	Checks                                                                               [2]int     // for ThreeCheck, checks given by white then black
+
+	pockets  [2][5]int  // for Crazyhouse, pieces in hand white then black, indexed by pocketSlot
+	promoted uint64     // for Crazyhouse, bitboard of pieces which were promoted from pawns
+	pieces   [12]uint64 // bitboard of each piece, indexed by pieceIndex
+	colours  [2]uint64  // bitboards of all white pieces and all black pieces
+	hash     uint64     // Zobrist key of the position
+	history  []uint64   // keys of every position reached, used to detect repetition
 }
 
 func NewChessboard() *Chessboard {
-	return &Chessboard{Board: [8][8]Piece{
+	cb := &Chessboard{FullMoveNumber: 1, Board: [8][8]Piece{
 		[8]Piece{'♜', '♞', '♝', '♛', '♚', '♝', '♞', '♜'},
 		[8]Piece{'♟', '♟', '♟', '♟', '♟', '♟', '♟', '♟'},
 		[8]Piece{0, 0, 0, 0, 0, 0, 0, 0},
@@ -59,7 +77,18 @@ func NewChessboard() *Chessboard {
 		[8]Piece{0, 0, 0, 0, 0, 0, 0, 0},
 		[8]Piece{'♙', '♙', '♙', '♙', '♙', '♙', '♙', '♙'},
 		[8]Piece{'♖', '♘', '♗', '♕', '♔', '♗', '♘', '♖'},
-	}}
+	}, RookFiles: [2][2]int8{{0, 7}, {0, 7}}}
+	cb.history = make([]uint64, 1)
+	cb.Sync()
+	return cb
+}
+
+// Copy returns a copy of the board which can be played on without affecting the original.
+func (cb *Chessboard) Copy() *Chessboard {
+	board := new(Chessboard)
+	*board = *cb
+	board.history = append([]uint64(nil), cb.history...)
+	return board
 }
 
 // TotalValue returns the total value worth of pieces the specified colour has
@@ -76,478 +105,206 @@ func (cb *Chessboard) TotalValue(black bool) (value int) {
 }
 
 // IsStalemated returns true if nobody can move.
+//
+// Deprecated: a stalemate only concerns the side to move, use IsStalemate.
 func (cb *Chessboard) IsStalemated() bool {
 	return cb.IsCheckmated(true) && cb.IsCheckmated(false)
 }
 
 // IsCheckmated returns true if the colour cannot move anywhere.
+//
+// Deprecated: this is also true for stalemate, use IsCheckmate or IsStalemate.
 func (cb *Chessboard) IsCheckmated(black bool) bool {
-	for y, _ := range cb.Board {
-		for x, p := range cb.Board[y] {
-			if p == 0 || IsBlack(p) != black {
-				continue
-			}
-			moves, enpassant, castleleft, castleright := cb.PossibleMoves(int8(x), int8(y))
-			if len(moves) > 0 || len(enpassant) > 0 || castleleft || castleright {
-				return false
-			}
-		}
-	}
-	return true
+	return len(cb.LegalMoves(black)) == 0
 }
 
-// Threat returns all threatened spaces by a particular colour.
-func (cb *Chessboard) Threat(black bool) (threatBoard [8][8]bool) {
-	var threatMoves [][2]int8
-	for y, _ := range cb.Board {
-		for x, p := range cb.Board[y] {
-			if p == 0 || IsBlack(p) != black {
-				continue
-			}
-			if possibleThreats := cb.PossibleThreats(int8(x), int8(y)); possibleThreats != nil {
-				threatMoves = append(threatMoves, possibleThreats...)
-			}
-		}
-	}
-	for _, pos := range threatMoves {
-		threatBoard[pos[1]][pos[0]] = true
-	}
-	return
-}
-
-// move returns a possible move (nil if none), and if it hit a piece
-func (cb *Chessboard) move(x, y int8, black bool) (*[2]int8, bool) {
-	if x < 0 || x > 7 || y < 0 || y > 7 {
-		return nil, false
-	}
-	if cb.Board[y][x] != 0 {
-		if IsBlack(cb.Board[y][x]) != black {
-			return &[2]int8{x, y}, true
-		}
-		return nil, true
-	}
-	return &[2]int8{x, y}, false
-}
-
-// pawnMoves returns both moves and threatened spaces
-func (cb *Chessboard) pawnMoves(x, y int8, black bool) (Moves, Threats [][2]int8, EnPassantKill *[2]int8) {
-	if black {
-		// regular movement
-		move, hit := cb.move(x, y+1, black)
-		if move != nil && !hit {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-			if y == 1 { // double-move
-				move, hit = cb.move(x, y+2, black)
-				if move != nil && !hit {
-					Moves = append(Moves, [2]int8{move[0], move[1]})
-				}
-			}
-		}
-		// kill moves
-		move, hit = cb.move(x+1, y+1, black)
-		if move != nil {
-			if hit {
-				Moves = append(Moves, [2]int8{move[0], move[1]})
-			}
-			Threats = append(Threats, [2]int8{move[0], move[1]})
-		} else if hit {
-			Threats = append(Threats, [2]int8{x + 1, y + 1})
-		}
-		move, hit = cb.move(x-1, y+1, black)
-		if move != nil && hit {
-			if hit {
-				Moves = append(Moves, [2]int8{move[0], move[1]})
-			}
-			Threats = append(Threats, [2]int8{move[0], move[1]})
-		} else if hit {
-			Threats = append(Threats, [2]int8{x - 1, y + 1})
-		}
-	} else {
-		move, hit := cb.move(x, y-1, black)
-		if move != nil && !hit {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-			if y == 6 { // double-move
-				move, hit = cb.move(x, y-2, black)
-				if move != nil && !hit {
-					Moves = append(Moves, [2]int8{move[0], move[1]})
-				}
-			}
-		}
-		// kill moves
-		move, hit = cb.move(x+1, y-1, black)
-		if move != nil {
-			if hit {
-				Moves = append(Moves, [2]int8{move[0], move[1]})
-			}
-			Threats = append(Threats, [2]int8{move[0], move[1]})
-		} else if hit {
-			Threats = append(Threats, [2]int8{x + 1, y - 1})
-		}
-		move, hit = cb.move(x-1, y-1, black)
-		if move != nil && hit {
-			if hit {
-				Moves = append(Moves, [2]int8{move[0], move[1]})
-			}
-			Threats = append(Threats, [2]int8{move[0], move[1]})
-		} else if hit {
-			Threats = append(Threats, [2]int8{x - 1, y - 1})
-		}
-	}
-	specialThreats := cb.canEnPassant(x, y)
-	if specialThreats != nil {
-		Threats = append(Threats, *specialThreats)
-		EnPassantKill = specialThreats
-	}
-	return
+// IsCheckmate returns true if the colour is in check and cannot move anywhere.
+func (cb *Chessboard) IsCheckmate(black bool) bool {
+	return cb.IsCheck(black) && len(cb.LegalMoves(black)) == 0
 }
 
-func (cb *Chessboard) kingMoves(x, y int8, black bool) (Moves [][2]int8) {
-	// up
-	move, _ := cb.move(x, y-1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// up-right
-	move, _ = cb.move(x+1, y-1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// right
-	move, _ = cb.move(x+1, y, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// down-right
-	move, _ = cb.move(x+1, y+1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// down
-	move, _ = cb.move(x, y+1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// down-left
-	move, _ = cb.move(x-1, y+1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// left
-	move, _ = cb.move(x-1, y, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// up-left
-	move, _ = cb.move(x-1, y-1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	return
+// IsStalemate returns true if the colour, being the side to move, isn't in check but cannot move anywhere.
+func (cb *Chessboard) IsStalemate(black bool) bool {
+	return !cb.IsCheck(black) && len(cb.LegalMoves(black)) == 0
 }
 
-func (cb *Chessboard) knightMoves(x, y int8, black bool) (Moves [][2]int8) {
-	// up-left
-	move, _ := cb.move(x-1, y-2, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// up-right
-	move, _ = cb.move(x+1, y-2, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// right-up
-	move, _ = cb.move(x+2, y-1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// right-down
-	move, _ = cb.move(x+2, y+1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// down-right
-	move, _ = cb.move(x+1, y+2, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// down-left
-	move, _ = cb.move(x-1, y+2, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// left-down
-	move, _ = cb.move(x-2, y+1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
-	}
-	// left-up
-	move, _ = cb.move(x-2, y-1, black)
-	if move != nil {
-		Moves = append(Moves, [2]int8{move[0], move[1]})
+// Threat returns all threatened spaces by a particular colour, indexed by Square.
+func (cb *Chessboard) Threat(black bool) (threatBoard [64]bool) {
+	threats := cb.threats(black)
+	for ; threats != 0; threats &= threats - 1 {
+		threatBoard[bits.TrailingZeros64(threats)] = true
 	}
 	return
 }
 
-func (cb *Chessboard) bishopMoves(x, y int8, black bool) (Moves [][2]int8) {
-	// up-left
-	for ty, tx := y-1, x-1; tx >= 0 && ty >= 0; tx, ty = tx-1, ty-1 {
-		move, hit := cb.move(tx, ty, black)
-		if move != nil {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-		}
-		if hit {
-			break
-		}
-	}
-	// up-right
-	for ty, tx := y-1, x+1; tx < 8 && ty >= 0; tx, ty = tx+1, ty-1 {
-		move, hit := cb.move(tx, ty, black)
-		if move != nil {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-		}
-		if hit {
-			break
+// threats returns the squares the colour attacks, along with any pawn it could take en passant.
+func (cb *Chessboard) threats(black bool) (threats uint64) {
+	occupied := cb.occupied()
+	c := colour(black)
+	for i := c * 6; i < c*6+6; i++ {
+		for pieces := cb.pieces[i]; pieces != 0; pieces &= pieces - 1 {
+			threats |= attacks(i, bits.TrailingZeros64(pieces), occupied)
 		}
 	}
-	// down-right
-	for ty, tx := y+1, x-1; tx >= 0 && ty < 8; tx, ty = tx-1, ty+1 {
-		move, hit := cb.move(tx, ty, black)
-		if move != nil {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-		}
-		if hit {
-			break
-		}
-	}
-	// down-left
-	for ty, tx := y+1, x+1; tx < 8 && ty <= 8; tx, ty = tx+1, ty+1 {
-		move, hit := cb.move(tx, ty, black)
-		if move != nil {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-		}
-		if hit {
-			break
-		}
+	if ep := cb.CanBeEnPassant; ep != nil && cb.enPassantTakers(black) != 0 {
+		threats |= bit(ep[0], ep[1])
 	}
 	return
 }
 
-func (cb *Chessboard) rookMoves(x, y int8, black bool) (Moves [][2]int8) {
-	// up
-	for ty := y - 1; ty >= 0; ty-- {
-		move, hit := cb.move(x, ty, black)
-		if move != nil {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-		}
-		if hit {
-			break
-		}
+// enPassantTakers returns the colour's pawns which can take the pawn in CanBeEnPassant, ignoring whether doing so
+// would leave their king in check.
+func (cb *Chessboard) enPassantTakers(black bool) uint64 {
+	ep := cb.CanBeEnPassant
+	if ep == nil || ep[1] < 2 || ep[1] > 5 {
+		return 0
 	}
-	// down
-	for ty := y + 1; ty < 8; ty++ {
-		move, hit := cb.move(x, ty, black)
-		if move != nil {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-		}
-		if hit {
-			break
-		}
+	victim, land := cb.Board[ep[1]][ep[0]], ep[1]-1
+	if black {
+		land = ep[1] + 1
 	}
-	// left
-	for tx := x - 1; tx >= 0; tx-- {
-		move, hit := cb.move(tx, y, black)
-		if move != nil {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-		}
-		if hit {
-			break
-		}
+	if (victim != WhitePawn && victim != BlackPawn) || IsBlack(victim) == black || cb.Board[land][ep[0]] != 0 {
+		return 0
 	}
-	// right
-	for tx := x + 1; tx < 8; tx++ {
-		move, hit := cb.move(tx, y, black)
-		if move != nil {
-			Moves = append(Moves, [2]int8{move[0], move[1]})
-		}
-		if hit {
-			break
-		}
+	var beside uint64
+	if ep[0] > 0 {
+		beside |= bit(ep[0]-1, ep[1])
 	}
-	return
+	if ep[0] < 7 {
+		beside |= bit(ep[0]+1, ep[1])
+	}
+	return beside & cb.pieces[colour(black)*6+pawnKind]
 }
 
 // Returns whether a particular colour is in check.
 func (cb *Chessboard) IsCheck(black bool) bool {
-	var kx, ky int
-	for y, row := range cb.Board {
-		for x, _ := range row {
-			if (black && cb.Board[y][x] == BlackKing) || (!black && cb.Board[y][x] == WhiteKing) {
-				kx, ky = x, y
-				break
-			}
-		}
+	if cb.Variant != nil {
+		return cb.Variant.IsCheck(cb, black)
 	}
-	return cb.Threat(!black)[ky][kx]
+	return cb.kingAttacked(black)
+}
+
+// kingAttacked returns true if the colour's king is attacked, which is check in standard chess.
+func (cb *Chessboard) kingAttacked(black bool) bool {
+	king := cb.pieces[colour(black)*6+kingKind]
+	return king != 0 && cb.attackers(bits.TrailingZeros64(king), !black, cb.occupied()) != 0
 }
 
 // Returns false if the move would put the player moving in check
 func (cb *Chessboard) TestMove(from, to [2]int8) bool {
-	board := new(Chessboard)
-	*board = *cb
-	board.Board[to[1]][to[0]] = board.Board[from[1]][from[0]]
-	board.Board[from[1]][from[0]] = 0
-	if board.IsCheck(IsBlack(board.Board[to[1]][to[0]])) {
+	if !onBoard(from) || !onBoard(to) {
 		return false
 	}
-	return true
+	return cb.testMove(cb.NewMove(from, to, RegularMove, 0))
 }
 
-// Performs a move and returns true if a move would result in a check for the opposing player
-func (cb *Chessboard) DoMove(from, to [2]int8) bool {
-	black := IsBlack(cb.Board[from[1]][from[0]])
-	cb.CanBeEnPassant = nil
-	if (black && from[1] == 1 && to[1] == 3 && cb.Board[from[1]][from[0]] == BlackPawn) || (!black && from[1] == 6 && to[1] == 4 && cb.Board[from[1]][from[0]] == WhitePawn) {
-		cb.CanBeEnPassant = &to
+// testMove returns false if the move would put the player moving in check
+func (cb *Chessboard) testMove(m Move) bool {
+	black := IsBlack(cb.moving(m))
+	cb.Make(m)
+	legal := !cb.kingAttacked(black)
+	if cb.Variant != nil {
+		legal = cb.Variant.Legal(cb, black)
 	}
-	if black {
-		if cb.Board[from[1]][from[0]] == BlackKing {
-			cb.BlackCantCastleLeft = true
-			cb.BlackCantCastleRight = true
-		} else if cb.Board[from[1]][from[0]] == BlackRook {
-			if from[0] == 0 && from[0] == 7 {
-				cb.BlackCantCastleLeft = true
-			} else if from[0] == 7 && from[0] == 7 {
-				cb.BlackCantCastleRight = true
-			}
-		}
-	} else {
-		if cb.Board[from[1]][from[0]] == WhiteKing {
-			cb.WhiteCantCastleLeft = true
-			cb.WhiteCantCastleRight = true
-		} else if cb.Board[from[1]][from[0]] == WhiteRook {
-			if from[0] == 0 && from[0] == 0 {
-				cb.WhiteCantCastleLeft = true
-			} else if from[0] == 7 && from[0] == 0 {
-				cb.WhiteCantCastleRight = true
-			}
-		}
+	cb.Unmake(m)
+	return legal
+}
+
+// Performs a move and returns true if a move would result in a check for the opposing player. Does nothing if it's
+// not the moving piece's turn.
+func (cb *Chessboard) DoMove(from, to [2]int8) bool {
+	if !cb.isTurn(from) || !onBoard(to) {
+		return false
 	}
-	cb.Board[to[1]][to[0]] = cb.Board[from[1]][from[0]]
-	cb.Board[from[1]][from[0]] = 0
-	if cb.IsCheck(!IsBlack(cb.Board[to[1]][to[0]])) {
-		return true
+	return cb.Make(cb.NewMove(from, to, RegularMove, 0))
+}
+
+// onBoard returns true if c are the coordinates of a square on the board.
+func onBoard(c [2]int8) bool {
+	return c[0] >= 0 && c[0] < 8 && c[1] >= 0 && c[1] < 8
+}
+
+// isTurn returns true if there's a piece at from, and it's that piece's turn to move.
+func (cb *Chessboard) isTurn(from [2]int8) bool {
+	if !onBoard(from) {
+		return false
 	}
-	return false
+	p := cb.Board[from[1]][from[0]]
+	return p != 0 && IsBlack(p) == cb.BlackMove
 }
 
 // Returns false if the move would put the player moving in check
 func (cb *Chessboard) TestEnPassant(from, to [2]int8) bool {
-	board := new(Chessboard)
-	*board = *cb
-	var modifier int8 // y modifier
-	if IsBlack(board.Board[from[1]][from[0]]) {
-		modifier = 1
-	} else {
-		modifier = -1
-	}
-	board.Board[to[1]][to[0]] = 0
-	board.Board[to[1]+modifier][to[0]] = board.Board[from[1]][from[0]]
-	board.Board[from[1]][from[0]] = 0
-	if board.IsCheck(IsBlack(board.Board[to[1]+modifier][to[0]])) {
+	if !cb.isEnPassant(from, to) {
 		return false
 	}
-	return true
+	return cb.testMove(cb.NewMove(from, to, EnPassant, 0))
 }
 
-// Performs a move and returns true if a move would result in a check for the opposing player
+// Performs a move and returns true if a move would result in a check for the opposing player. Does nothing if it's
+// not the moving piece's turn.
 func (cb *Chessboard) DoEnPassant(from, to [2]int8) bool {
-	var modifier int8 // y modifier
-	if IsBlack(cb.Board[from[1]][from[0]]) {
-		modifier = 1
-	} else {
-		modifier = -1
-	}
-	cb.Board[to[1]][to[0]] = 0
-	cb.Board[to[1]+modifier][to[0]] = cb.Board[from[1]][from[0]]
-	cb.Board[from[1]][from[0]] = 0
-	cb.CanBeEnPassant = nil
-	if cb.IsCheck(!IsBlack(cb.Board[to[1]+modifier][to[0]])) {
-		return true
+	if !cb.isEnPassant(from, to) {
+		return false
 	}
-	return false
+	return cb.Make(cb.NewMove(from, to, EnPassant, 0))
+}
+
+// isEnPassant returns true if it's the turn of the piece at from, and to is the pawn which can be taken en passant.
+func (cb *Chessboard) isEnPassant(from, to [2]int8) bool {
+	return cb.isTurn(from) && cb.CanBeEnPassant != nil && *cb.CanBeEnPassant == to
 }
 
 // Returns false if the move would put the player moving in check
 func (cb *Chessboard) TestCastle(from [2]int8, left bool) bool {
-	board := new(Chessboard)
-	*board = *cb
-	black := IsBlack(board.Board[from[1]][from[0]])
-	if left {
-		board.Board[from[1]][2] = board.Board[from[1]][from[0]]
-		board.Board[from[1]][from[0]] = 0
-		board.Board[from[1]][3] = board.Board[from[1]][0]
-		board.Board[from[1]][0] = 0
-	} else {
-		board.Board[from[1]][6] = board.Board[from[1]][from[0]]
-		board.Board[from[1]][from[0]] = 0
-		board.Board[from[1]][5] = board.Board[from[1]][7]
-		board.Board[from[1]][7] = 0
-	}
-	if board.IsCheck(black) {
+	if !onBoard(from) {
 		return false
 	}
-	return true
+	if left {
+		return cb.testMove(cb.NewMove(from, from, CastleLeft, 0))
+	}
+	return cb.testMove(cb.NewMove(from, from, CastleRight, 0))
 }
 
-// Performs a move and returns true if a move would result in a check for the opposing player
+// Performs a move and returns true if a move would result in a check for the opposing player. Does nothing if it's
+// not the king's turn.
 func (cb *Chessboard) DoCastle(from [2]int8, left bool) bool {
-	black := IsBlack(cb.Board[from[1]][from[0]])
-	if left {
-		cb.Board[from[1]][2] = cb.Board[from[1]][from[0]]
-		cb.Board[from[1]][from[0]] = 0
-		cb.Board[from[1]][3] = cb.Board[from[1]][0]
-		cb.Board[from[1]][0] = 0
-	} else {
-		cb.Board[from[1]][6] = cb.Board[from[1]][from[0]]
-		cb.Board[from[1]][from[0]] = 0
-		cb.Board[from[1]][5] = cb.Board[from[1]][7]
-		cb.Board[from[1]][7] = 0
-	}
-	if black {
-		cb.BlackCantCastleLeft = true
-		cb.BlackCantCastleRight = true
-	} else {
-		cb.WhiteCantCastleLeft = true
-		cb.WhiteCantCastleRight = true
+	if !cb.isTurn(from) {
+		return false
 	}
-	if cb.IsCheck(!black) {
-		return true
+	if left {
+		return cb.Make(cb.NewMove(from, from, CastleLeft, 0))
 	}
-	return false
+	return cb.Make(cb.NewMove(from, from, CastleRight, 0))
 }
 
 // PromotePawn promotes a pawn at x, y, returns true on success, and false on failure.
 func (cb *Chessboard) PromotePawn(x, y int8, to Piece) bool {
-	if y != 0 && y != 7 {
+	if x < 0 || x > 7 || (y != 0 && y != 7) {
 		return false
 	}
-	piece := cb.Board[y][x]
-	black := IsBlack(piece)
-	switch to {
-	case WhiteRook, WhiteKnight, WhiteQueen, WhiteBishop:
-		if !black {
-			cb.Board[y][x] = to
-			return true
-		}
-	case BlackRook, BlackKnight, BlackQueen, BlackBishop:
-		if black {
-			cb.Board[y][x] = to
-			return true
-		}
+	if !cb.canPromoteTo(to, IsBlack(cb.Board[y][x])) {
+		return false
 	}
+	cb.promote(x, y, to)
+	return true
+}
 
-	return false
+// promote replaces the pawn at x, y with the piece it's promoted to.
+func (cb *Chessboard) promote(x, y int8, to Piece) {
+	cb.hash ^= cb.stateKey()
+	_, threeCheck := cb.Variant.(ThreeCheck)
+	checked := threeCheck && cb.kingAttacked(cb.BlackMove)
+	cb.set(x, y, to)
+	cb.promoted |= bit(x, y)
+	// in Three-check, a check given by the promoted piece counts as one given by the pawn's move
+	if threeCheck && !checked && cb.kingAttacked(cb.BlackMove) {
+		cb.Checks[colour(!cb.BlackMove)]++
+	}
+	cb.hash ^= cb.stateKey()
+	if len(cb.history) > 0 {
+		cb.history[len(cb.history)-1] = cb.hash
+	}
 }
 
 type MoveType int
@@ -557,21 +314,25 @@ const (
 	EnPassant
 	CastleLeft
 	CastleRight
+	Drop // places a piece from the pocket in Crazyhouse, From being the same as To
 )
 
-// Checks if a piece can move from the from position, to the to position.
+// Checks if a piece can move from the from position, to the to position, and that it's that piece's turn.
 func (cb *Chessboard) IsLegal(from, to [2]int8, movet MoveType) bool {
-	moves, enpassantkill, castleleft, castleright := cb.PossibleMoves(from[0], from[1])
+	if !cb.isTurn(from) {
+		return false
+	}
+	moves, enpassantkill, castleleft, castleright := cb.PossibleMoves(SquareAt(from[0], from[1]))
 	switch movet {
 	case RegularMove:
 		for _, move := range moves {
-			if to == move {
+			if SquareAt(to[0], to[1]) == move {
 				return true
 			}
 		}
 	case EnPassant:
 		for _, move := range enpassantkill {
-			if to == move {
+			if SquareAt(to[0], to[1]) == move {
 				return true
 			}
 		}
@@ -584,134 +345,87 @@ func (cb *Chessboard) IsLegal(from, to [2]int8, movet MoveType) bool {
 }
 
 // canCastle checks if the king at x/y can castle. Returns 2 bools, first is `CanCastleLeft`, second is `CanCastleRight`.
+// The king can't castle out of, through, or into check.
 func (cb *Chessboard) canCastle(x, y int8) (CanCastleLeft, CanCastleRight bool) {
 	if cb.Board[y][x] != BlackKing && cb.Board[y][x] != WhiteKing {
 		return
 	}
 	black := IsBlack(cb.Board[y][x])
-	if ((black && !cb.BlackCantCastleLeft) || (!black && !cb.WhiteCantCastleLeft)) && cb.Board[y][x-1] == 0 && cb.Board[y][x-2] == 0 && cb.Board[y][x-3] == 0 {
-		CanCastleLeft = true
-	}
-	if ((black && !cb.BlackCantCastleRight) || (!black && !cb.WhiteCantCastleRight)) && cb.Board[y][x+1] == 0 && cb.Board[y][x+2] == 0 {
-		CanCastleRight = true
+	if (black && y != 0) || (!black && y != 7) {
+		return
 	}
+	CanCastleLeft = !*cb.cantCastle(black, true) && cb.canCastleSide(x, y, true)
+	CanCastleRight = !*cb.cantCastle(black, false) && cb.canCastleSide(x, y, false)
 	return
 }
 
-// Should return all legal moves by a piece at x, y.
-func (cb *Chessboard) PossibleMoves(x, y int8) (OutMoves, OutEnPassantKill [][2]int8, CanCastleLeft, CanCastleRight bool) {
+// cantCastle returns the flag saying whether the colour has lost the right to castle on the specified side.
+func (cb *Chessboard) cantCastle(black, left bool) *bool {
+	switch {
+	case black && left:
+		return &cb.BlackCantCastleLeft
+	case black:
+		return &cb.BlackCantCastleRight
+	case left:
+		return &cb.WhiteCantCastleLeft
+	}
+	return &cb.WhiteCantCastleRight
+}
+
+// Should return all legal moves by a piece on s. For en passant, the squares are those of the pawns being taken.
+func (cb *Chessboard) PossibleMoves(s Square) (OutMoves, OutEnPassantKill []Square, CanCastleLeft, CanCastleRight bool) {
 	var (
-		Moves         [][2]int8
-		EnPassantKill *[2]int8
+		buf   [maxMoves]Move
+		moves []Move
 	)
-	switch P := cb.Board[y][x]; P {
-	case BlackRook:
-		Moves = append(Moves, cb.rookMoves(x, y, true)...)
-	case WhiteRook:
-		Moves = append(Moves, cb.rookMoves(x, y, false)...)
-	case BlackKnight:
-		Moves = append(Moves, cb.knightMoves(x, y, true)...)
-	case WhiteKnight:
-		Moves = append(Moves, cb.knightMoves(x, y, false)...)
-	case BlackBishop:
-		Moves = append(Moves, cb.bishopMoves(x, y, true)...)
-	case WhiteBishop:
-		Moves = append(Moves, cb.bishopMoves(x, y, false)...)
-	case BlackQueen:
-		Moves = append(Moves, cb.rookMoves(x, y, true)...)
-		Moves = append(Moves, cb.bishopMoves(x, y, true)...)
-	case WhiteQueen:
-		Moves = append(Moves, cb.rookMoves(x, y, false)...)
-		Moves = append(Moves, cb.bishopMoves(x, y, false)...)
-	case BlackPawn:
-		var pawnMoves [][2]int8
-		pawnMoves, _, EnPassantKill = cb.pawnMoves(x, y, true)
-		Moves = append(Moves, pawnMoves...)
-	case WhitePawn:
-		var pawnMoves [][2]int8
-		pawnMoves, _, EnPassantKill = cb.pawnMoves(x, y, false)
-		Moves = append(Moves, pawnMoves...)
-	case BlackKing:
-		Moves = append(Moves, cb.kingMoves(x, y, true)...)
-		CanCastleLeft, CanCastleRight = cb.canCastle(x, y)
-	case WhiteKing:
-		Moves = append(Moves, cb.kingMoves(x, y, false)...)
-		CanCastleLeft, CanCastleRight = cb.canCastle(x, y)
-	}
-
-	// Test if they're *really* possible.
-	from := [2]int8{x, y}
-	for _, move := range Moves {
-		if cb.TestMove(from, move) {
-			OutMoves = append(OutMoves, move)
-		}
+	if !s.Valid() {
+		return
 	}
-	if EnPassantKill != nil {
-		move := *EnPassantKill
-		if cb.TestEnPassant(from, move) {
-			OutEnPassantKill = [][2]int8{move}
+	x, y := s.X(), s.Y()
+	if cb.Variant == nil {
+		moves = cb.appendMoves(buf[:0], x, y)
+	} else if p := cb.Board[y][x]; isPiece(p) {
+		// a variant may restrict a piece's moves by what the others can do
+		for _, m := range cb.AppendLegalMoves(buf[:0], IsBlack(p)) {
+			if m.From == [2]int8{x, y} && m.Type != Drop {
+				moves = append(moves, m)
+			}
 		}
 	}
-	if CanCastleLeft {
-		CanCastleLeft = cb.TestCastle(from, true)
-	}
-	if CanCastleRight {
-		CanCastleRight = cb.TestCastle(from, false)
+	for _, m := range moves {
+		switch m.Type {
+		case RegularMove:
+			// a promotion has a move for each piece, only list the first
+			if m.Promotion == 0 || m.Promotion == WhiteQueen || m.Promotion == BlackQueen {
+				OutMoves = append(OutMoves, SquareAt(m.To[0], m.To[1]))
+			}
+		case EnPassant:
+			OutEnPassantKill = append(OutEnPassantKill, SquareAt(m.To[0], m.To[1]))
+		case CastleLeft:
+			CanCastleLeft = true
+		case CastleRight:
+			CanCastleRight = true
+		}
 	}
 	return
 }
 
-// canEnPassant returns a piece the pawn in x, y could eliminate via en passant or nil.
-func (cb *Chessboard) canEnPassant(x, y int8) *[2]int8 {
-	if cb.Board[y][x] != BlackPawn && cb.Board[y][x] != WhitePawn || cb.CanBeEnPassant == nil || y < 2 || y > 5 {
-		return nil
+// PossibleThreats returns all the possibly threatened spaces.
+func (cb *Chessboard) PossibleThreats(x, y int8) (Moves [][2]int8) {
+	if !onBoard([2]int8{x, y}) {
+		return
 	}
-	var modifier int8 // y modifier
-	if IsBlack(cb.Board[y][x]) {
-		modifier = 1
-	} else {
-		modifier = -1
+	p := cb.Board[y][x]
+	if !isPiece(p) {
+		return
 	}
-	var out *[2]int8
-	black := IsBlack(cb.Board[y][x])
-	move := *cb.CanBeEnPassant
-	if y == move[1] && IsBlack(cb.Board[move[1]][move[0]]) != black && cb.Board[move[1]+modifier][move[0]] == 0 {
-		if x > 0 && x-1 == move[0] {
-			out = &[2]int8{move[0], move[1]}
-		}
-		if x < 7 && x+1 == move[0] {
-			out = &[2]int8{move[0], move[1]}
-		}
+	threats := attacks(pieceIndex(p), square(x, y), cb.occupied())
+	if ep := cb.CanBeEnPassant; ep != nil && cb.enPassantTakers(IsBlack(p))&bit(x, y) != 0 {
+		threats |= bit(ep[0], ep[1])
 	}
-	return out
-}
-
-// PossibleThreats returns all the possibly threatened spaces, can contain duplicates
-func (cb *Chessboard) PossibleThreats(x, y int8) (Moves [][2]int8) {
-	switch P := cb.Board[y][x]; P {
-	case BlackRook, WhiteRook:
-		Moves = append(Moves, cb.rookMoves(x, y, true)...)
-		Moves = append(Moves, cb.rookMoves(x, y, false)...)
-	case BlackKnight, WhiteKnight:
-		Moves = append(Moves, cb.knightMoves(x, y, true)...)
-		Moves = append(Moves, cb.knightMoves(x, y, false)...)
-	case BlackBishop, WhiteBishop:
-		Moves = append(Moves, cb.bishopMoves(x, y, true)...)
-		Moves = append(Moves, cb.bishopMoves(x, y, false)...)
-	case BlackQueen, WhiteQueen:
-		Moves = append(Moves, cb.rookMoves(x, y, true)...)
-		Moves = append(Moves, cb.bishopMoves(x, y, true)...)
-		Moves = append(Moves, cb.rookMoves(x, y, false)...)
-		Moves = append(Moves, cb.bishopMoves(x, y, false)...)
-	case BlackPawn:
-		_, pawnThreats, _ := cb.pawnMoves(x, y, true)
-		Moves = append(Moves, pawnThreats...)
-	case WhitePawn:
-		_, pawnThreats, _ := cb.pawnMoves(x, y, false)
-		Moves = append(Moves, pawnThreats...)
-	case BlackKing, WhiteKing:
-		Moves = append(Moves, cb.kingMoves(x, y, true)...)
-		Moves = append(Moves, cb.kingMoves(x, y, false)...)
+	for ; threats != 0; threats &= threats - 1 {
+		sq := bits.TrailingZeros64(threats)
+		Moves = append(Moves, [2]int8{int8(sq % 8), int8(sq / 8)})
 	}
 	return
 }
@@ -0,0 +1,133 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// chess960Knights are the squares (among the 5 left once the bishops and queen are placed) of the knights, by the
+// remainder of the index.
+var chess960Knights = [10][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}
+
+// Chess960Rank returns the back rank of a Chess960 starting position, numbered 0 to 959 as in the standard
+// (Scharnagl) numbering, where 518 is the usual starting position. The pieces are white's, from the a-file to the
+// h-file.
+func Chess960Rank(n int) (rank [8]Piece, err error) {
+	if n < 0 || n > 959 {
+		err = fmt.Errorf("Chess960 position %d is not between 0 and 959", n)
+		return
+	}
+	rank[n%4*2+1] = WhiteBishop // light squares
+	n /= 4
+	rank[n%4*2] = WhiteBishop // dark squares
+	n /= 4
+	// place remaining pieces on the i'th empty square
+	place := func(i int, p Piece) {
+		for x := range rank {
+			if rank[x] != 0 {
+				continue
+			}
+			if i == 0 {
+				rank[x] = p
+				return
+			}
+			i--
+		}
+	}
+	place(n%6, WhiteQueen)
+	n /= 6
+	knights := chess960Knights[n]
+	place(knights[1], WhiteKnight) // the second knight first, so the first's square doesn't shift
+	place(knights[0], WhiteKnight)
+	place(0, WhiteRook)
+	place(0, WhiteKing)
+	place(0, WhiteRook)
+	return
+}
+
+// NewChess960 returns a board set up in Chess960 starting position n, numbered 0 to 959 (see Chess960Rank).
+func NewChess960(n int) (*Chessboard, error) {
+	rank, err := Chess960Rank(n)
+	if err != nil {
+		return nil, err
+	}
+	cb := &Chessboard{FullMoveNumber: 1, Chess960: true}
+	rooks := 0
+	for x, p := range rank {
+		cb.Board[7][x] = p
+		cb.Board[6][x] = WhitePawn
+		cb.Board[1][x] = BlackPawn
+		cb.Board[0][x] = p + (BlackKing - WhiteKing)
+		if p == WhiteRook { // the king is always between the rooks, so the first is on the left
+			cb.RookFiles[0][rooks] = int8(x)
+			rooks++
+		}
+	}
+	cb.RookFiles[1] = cb.RookFiles[0]
+	cb.history = make([]uint64, 1)
+	cb.Sync()
+	return cb, nil
+}
+
+// CastleFile returns the file of the rook the colour castles with on the left or right. Outside of Chess960 these
+// are always the a-file and h-file.
+func (cb *Chessboard) CastleFile(black, left bool) int8 {
+	side := 1
+	if left {
+		side = 0
+	}
+	if !cb.Chess960 {
+		return int8(side * 7)
+	}
+	return cb.RookFiles[colour(black)][side]
+}
+
+// span returns the squares on row y from x1 to x2, inclusive.
+func span(x1, x2, y int8) (squares uint64) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	for x := x1; x <= x2; x++ {
+		squares |= bit(x, y)
+	}
+	return
+}
+
+// castleSquares returns where the king and rook end up after castling.
+func castleSquares(left bool) (king, rook int8) {
+	if left {
+		return 2, 3
+	}
+	return 6, 5
+}
+
+// canCastleSide returns true if the king at x, y has a rook to castle with on the specified side, every square the
+// king and rook cross is empty, and the king doesn't start on, cross or land on an attacked square. It doesn't
+// check castling rights.
+func (cb *Chessboard) canCastleSide(x, y int8, left bool) bool {
+	black := IsBlack(cb.Board[y][x])
+	rookX := cb.CastleFile(black, left)
+	rook := WhiteRook
+	if black {
+		rook = BlackRook
+	}
+	if cb.Board[y][rookX] != rook || (left && rookX >= x) || (!left && rookX <= x) {
+		return false
+	}
+	kingTo, rookTo := castleSquares(left)
+	moving := bit(x, y) | bit(rookX, y)
+	occupied := cb.occupied() &^ moving
+	if occupied&(span(x, kingTo, y)|span(rookX, rookTo, y)) != 0 {
+		return false
+	}
+	// with the rook already moved, so it can't hide an attack on where the king lands
+	occupied |= bit(kingTo, y) | bit(rookTo, y)
+	for squares := span(x, kingTo, y); squares != 0; squares &= squares - 1 {
+		if cb.attackers(bits.TrailingZeros64(squares), !black, occupied) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+// chess960Positions are Chess960 perft reference positions.
+var chess960Positions = []struct {
+	fen   string
+	nodes []uint64
+}{
+	{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", []uint64{21, 528, 12189, 326672}},
+	{"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", []uint64{21, 807, 18002, 667366}},
+	{"1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9", []uint64{28, 1120, 31058, 1171749}},
+}
+
+func TestChess960Perft(t *testing.T) {
+	for _, pos := range chess960Positions {
+		cb, err := ParseFEN(pos.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cb.Chess960 {
+			t.Errorf("%s: not parsed as Chess960", pos.fen)
+		}
+		if fen := cb.ShredderFEN(); fen != pos.fen {
+			t.Errorf("ShredderFEN() = %q, want %q", fen, pos.fen)
+		}
+		for i, want := range pos.nodes {
+			if testing.Short() && want > 100000 {
+				break
+			}
+			if got := cb.Perft(i + 1); got != want {
+				t.Errorf("%s depth %d: got %d nodes, want %d", pos.fen, i+1, got, want)
+			}
+		}
+	}
+}
+
+func TestChess960Rank(t *testing.T) {
+	for n, want := range map[int]string{0: "BBQNNRKR", 518: "RNBQKBNR", 959: "RKRNNQBB"} {
+		rank, err := Chess960Rank(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		for _, p := range rank {
+			got += string(Letter(p))
+		}
+		if got != want {
+			t.Errorf("Chess960Rank(%d) = %s, want %s", n, got, want)
+		}
+	}
+	if _, err := Chess960Rank(960); err == nil {
+		t.Error("Chess960Rank(960) didn't fail")
+	}
+
+	seen := make(map[[8]Piece]bool)
+	for n := 0; n < 960; n++ {
+		rank, _ := Chess960Rank(n)
+		seen[rank] = true
+	}
+	if len(seen) != 960 {
+		t.Errorf("got %d distinct positions, want 960", len(seen))
+	}
+}
+
+func TestChess960FEN(t *testing.T) {
+	for _, tt := range []struct {
+		n             int
+		fen, shredder string
+	}{
+		{518, StartingFEN, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1"},
+		{0, "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1", "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w HFhf - 0 1"},
+	} {
+		cb, err := NewChess960(tt.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fen := cb.FEN(); fen != tt.fen {
+			t.Errorf("position %d: FEN() = %q, want %q", tt.n, fen, tt.fen)
+		}
+		if fen := cb.ShredderFEN(); fen != tt.shredder {
+			t.Errorf("position %d: ShredderFEN() = %q, want %q", tt.n, fen, tt.shredder)
+		}
+		parsed, err := ParseFEN(tt.shredder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Hash() != cb.Hash() || parsed.RookFiles != cb.RookFiles {
+			t.Errorf("position %d: parsed Shredder-FEN differs from the generated board", tt.n)
+		}
+	}
+
+	// an inner rook needs its file in X-FEN
+	cb, err := ParseFEN("4k3/8/8/8/8/8/8/R1R1K3 w C - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen := cb.FEN(); fen != "4k3/8/8/8/8/8/8/R1R1K3 w C - 0 1" {
+		t.Errorf("FEN() = %q", fen)
+	}
+	if !cb.IsLegal([2]int8{4, 7}, [2]int8{2, 7}, CastleLeft) {
+		t.Error("can't castle with the c-file rook")
+	}
+}
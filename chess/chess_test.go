@@ -0,0 +1,56 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+func TestCheckmateAndStalemate(t *testing.T) {
+	for _, test := range []struct {
+		name, fen              string
+		check, mate, stalemate bool // for the side to move
+	}{
+		{"start", StartingFEN, false, false, false},
+		{"mate", "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", true, true, false},
+		{"back rank mate", "R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1", true, true, false},
+		{"stalemate", "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", false, false, true},
+		{"stalemate in the corner", "k7/8/1Q6/8/8/8/8/7K b - - 0 1", false, false, true},
+		{"check", "R3k3/8/8/8/8/8/8/4K3 b - - 0 1", true, false, false},
+		{"check by a knight", "4k3/8/3N4/8/8/8/8/4K3 b - - 0 1", true, false, false},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		black := cb.BlackMove
+		if cb.IsCheck(black) != test.check || cb.IsCheckmate(black) != test.mate || cb.IsStalemate(black) != test.stalemate {
+			t.Errorf("%s: IsCheck(), IsCheckmate(), IsStalemate() = %v, %v, %v, want %v, %v, %v", test.name,
+				cb.IsCheck(black), cb.IsCheckmate(black), cb.IsStalemate(black), test.check, test.mate, test.stalemate)
+		}
+		// the side which just moved can always move
+		if cb.IsCheckmate(!black) || cb.IsStalemate(!black) {
+			t.Errorf("%s: the side which just moved is mated or stalemated", test.name)
+		}
+	}
+}
+
+func TestWrongSideToMove(t *testing.T) {
+	for _, test := range []struct {
+		name, fen string
+		do        func(cb *Chessboard)
+	}{
+		{"DoMove", StartingFEN, func(cb *Chessboard) { cb.DoMove([2]int8{4, 1}, [2]int8{4, 3}) }},
+		{"DoCastle left", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", func(cb *Chessboard) { cb.DoCastle([2]int8{4, 0}, true) }},
+		{"DoCastle right", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", func(cb *Chessboard) { cb.DoCastle([2]int8{4, 0}, false) }},
+		{"DoEnPassant", "4k3/8/8/8/2PPp3/8/8/4K3 b - d3 0 1", func(cb *Chessboard) { cb.DoEnPassant([2]int8{2, 4}, [2]int8{3, 4}) }},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash := cb.Hash()
+		test.do(cb)
+		if cb.FEN() != test.fen || cb.Hash() != hash {
+			t.Errorf("%s: a move by the side not to move changed %s to %s", test.name, test.fen, cb.FEN())
+		}
+	}
+}
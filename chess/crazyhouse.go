@@ -0,0 +1,107 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "math/bits"
+
+// Crazyhouse is chess where captured pieces go into the capturer's pocket, and can be dropped back onto the board as
+// a move of their own. A captured piece which was promoted goes into the pocket as a pawn.
+type Crazyhouse struct{ Standard }
+
+// backRanks are the first and last ranks, which pawns can't be dropped on.
+const backRanks = 0xFF000000000000FF
+
+func (Crazyhouse) Name() string { return "Crazyhouse" }
+
+func (Crazyhouse) AfterMove(cb *Chessboard, m Move) {
+	if c, i, ok := pocketed(m); ok {
+		cb.pockets[c][i]++
+	}
+	if m.Type == RegularMove {
+		from, to := bit(m.From[0], m.From[1]), bit(m.To[0], m.To[1])
+		cb.promoted &^= to
+		if m.PrevPromoted&from != 0 || m.Promotion != 0 {
+			cb.promoted = cb.promoted&^from | to
+		}
+	}
+}
+
+func (Crazyhouse) UndoMove(cb *Chessboard, m Move) {
+	if c, i, ok := pocketed(m); ok {
+		cb.pockets[c][i]--
+	}
+	cb.promoted = m.PrevPromoted
+}
+
+// pocketed returns the pocket slot the piece a move captures goes into, and false if it doesn't go into one.
+func pocketed(m Move) (c, i int, ok bool) {
+	if m.Captured == 0 || m.Captured == WhiteKing || m.Captured == BlackKing {
+		return
+	}
+	c, i = pocketSlot(m.Captured)
+	if m.PrevPromoted&bit(m.To[0], m.To[1]) != 0 {
+		i = pawnKind - 1
+	}
+	return 1 - c, i, true
+}
+
+// pocketSlot returns where a piece is kept in the pockets: its colour, then its kind less one (kings can't be kept).
+func pocketSlot(p Piece) (c, i int) {
+	i = pieceIndex(p)
+	return i / 6, i%6 - 1
+}
+
+// Pocket returns how many of a piece are in its colour's pocket, for Crazyhouse.
+func (cb *Chessboard) Pocket(p Piece) int {
+	if !isPiece(p) || p == WhiteKing || p == BlackKing {
+		return 0
+	}
+	c, i := pocketSlot(p)
+	return cb.pockets[c][i]
+}
+
+// appendDrops appends the legal drops the colour can make to moves.
+func (cb *Chessboard) appendDrops(moves []Move, black bool) []Move {
+	c := colour(black)
+	empty := ^cb.occupied()
+	for i, n := range cb.pockets[c] {
+		if n == 0 {
+			continue
+		}
+		p, targets := WhiteKing+Piece(c*6+i+1), empty
+		if i == pawnKind-1 {
+			targets &^= backRanks
+		}
+		for ; targets != 0; targets &= targets - 1 {
+			to := targets & -targets
+			if cb.Variant == nil && !cb.kingSafe(0, to, 0, black) {
+				continue
+			}
+			sq := bits.TrailingZeros64(to)
+			dest := [2]int8{int8(sq % 8), int8(sq / 8)}
+			moves = cb.appendLegal(moves, cb.NewMove(dest, dest, Drop, p))
+		}
+	}
+	return moves
+}
+
+// CanDrop returns true if p can be dropped from its pocket onto to: it's p's turn, the square is empty, and the drop
+// doesn't leave the player in check. Pawns can't be dropped on the first or last rank.
+func (cb *Chessboard) CanDrop(p Piece, to [2]int8) bool {
+	if cb.Pocket(p) == 0 || IsBlack(p) != cb.BlackMove || to[0] < 0 || to[0] > 7 || to[1] < 0 || to[1] > 7 {
+		return false
+	}
+	if cb.Board[to[1]][to[0]] != 0 || ((p == WhitePawn || p == BlackPawn) && (to[1] == 0 || to[1] == 7)) {
+		return false
+	}
+	return cb.testMove(cb.NewMove(to, to, Drop, p))
+}
+
+// DoDrop drops p from its pocket onto to, and returns true if the opposing player is now in check. Does nothing if
+// the drop isn't legal.
+func (cb *Chessboard) DoDrop(p Piece, to [2]int8) bool {
+	if !cb.CanDrop(p, to) {
+		return false
+	}
+	return cb.Make(cb.NewMove(to, to, Drop, p))
+}
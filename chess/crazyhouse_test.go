@@ -0,0 +1,129 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+func TestCrazyhousePerft(t *testing.T) {
+	for _, pos := range []struct {
+		fen   string
+		nodes []uint64
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1", []uint64{20, 400, 8902, 197281, 4888832}},
+		{"2k5/8/8/8/8/8/8/4K3[QRBNPqrbnp] w - - 0 1", []uint64{301, 75353}},
+	} {
+		cb, err := ParseFEN(pos.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range pos.nodes {
+			if testing.Short() && want > 100000 {
+				break
+			}
+			if got := cb.Perft(i + 1); got != want {
+				t.Errorf("%s depth %d: got %d nodes, want %d", pos.fen, i+1, got, want)
+			}
+		}
+		if fen := cb.FEN(); fen != pos.fen {
+			t.Errorf("position changed after perft: %s", fen)
+		}
+	}
+}
+
+func TestCrazyhouseFEN(t *testing.T) {
+	for _, fen := range []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1",
+		"r1bk3r/ppp2ppp/8/4N3/8/8/PPP2PPP/R3K1NR[QNPPbn] b KQ - 0 12",
+		"Q~3k3/8/8/8/8/8/8/4K3[p] b - - 0 40",
+	} {
+		cb, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cb.Variant.(Crazyhouse); !ok {
+			t.Errorf("%s: not parsed as Crazyhouse", fen)
+		}
+		if got := cb.FEN(); got != fen {
+			t.Errorf("FEN() = %q, want %q", got, fen)
+		}
+	}
+
+	cb, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/Nq w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cb.Pocket(WhiteKnight) != 1 || cb.Pocket(BlackQueen) != 1 || cb.Pocket(WhitePawn) != 0 {
+		t.Errorf("ninth rank pocket parsed as %s", cb.FEN())
+	}
+	for _, fen := range []string{
+		"8/8/8/8/8/8/8/8[K] w - - 0 1",
+		"8/8/8/8/8/8/8/8[Q w - - 0 1",
+		"~8/8/8/8/8/8/8/8[] w - - 0 1",
+	} {
+		if _, err := ParseFEN(fen); err == nil {
+			t.Errorf("ParseFEN(%q) didn't fail", fen)
+		}
+	}
+}
+
+func TestDrop(t *testing.T) {
+	cb, err := ParseFEN("r1bk3r/ppp2ppp/8/4N3/8/8/PPP2PPP/R3K1NR[QNPPbn] w KQ - 0 12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, hash := cb.FEN(), cb.Hash()
+	if cb.CanDrop(WhitePawn, [2]int8{0, 0}) || cb.CanDrop(WhitePawn, [2]int8{3, 7}) {
+		t.Error("pawn dropped on the first or last rank")
+	}
+	if cb.CanDrop(BlackKnight, [2]int8{3, 4}) {
+		t.Error("black dropped on white's move")
+	}
+	if cb.CanDrop(WhiteRook, [2]int8{3, 4}) {
+		t.Error("dropped a rook which isn't in the pocket")
+	}
+	m := cb.NewMove([2]int8{3, 1}, [2]int8{3, 1}, Drop, WhiteQueen)
+	cb.Make(m)
+	if cb.Board[1][3] != WhiteQueen || cb.Pocket(WhiteQueen) != 0 {
+		t.Errorf("Qd7 drop left %s", cb.FEN())
+	}
+	cb.Unmake(m)
+	if fen := cb.FEN(); fen != before || cb.Hash() != hash {
+		t.Errorf("Unmake() left %s, want %s", fen, before)
+	}
+
+	san, err := cb.SAN(m.From, m.To, Drop, WhiteQueen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if san != "Q@d7+" {
+		t.Errorf("SAN() = %q, want Q@d7+", san)
+	}
+	if err := cb.DoSAN("N@c6+"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.DoSAN("Kd7"); err == nil {
+		t.Error("king moved into check")
+	}
+}
+
+func TestCrazyhouseCapture(t *testing.T) {
+	cb, err := ParseFEN("4k3/8/8/8/8/4K3/8/Q~6r[] b - - 0 40")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.DoSAN("Rxa1"); err != nil {
+		t.Fatal(err)
+	}
+	if cb.Pocket(BlackPawn) != 1 || cb.Pocket(BlackQueen) != 0 {
+		t.Errorf("captured promoted queen went into the pocket as %s", cb.FEN())
+	}
+	if err := cb.DoSAN("Kd2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.DoSAN("P@d3"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "4k3/8/8/8/8/3p4/3K4/r7[] w - - 0 42"; cb.FEN() != want {
+		t.Errorf("FEN() = %q, want %q", cb.FEN(), want)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+// Repetitions returns how many times the current position has occurred, including now.
+func (cb *Chessboard) Repetitions() int {
+	if len(cb.history) == 0 {
+		return 1
+	}
+	n := 0
+	current := cb.history[len(cb.history)-1]
+	// positions before the last capture or pawn move can't repeat
+	for i := len(cb.history) - 1; i >= 0 && i >= len(cb.history)-1-cb.HalfMoveClock; i -= 2 {
+		if cb.history[i] == current {
+			n++
+		}
+	}
+	return n
+}
+
+// IsThreefoldRepetition returns true if the current position has occurred 3 times, which allows a draw to be claimed.
+func (cb *Chessboard) IsThreefoldRepetition() bool {
+	return cb.Repetitions() >= 3
+}
+
+// IsFivefoldRepetition returns true if the current position has occurred 5 times, which draws the game.
+func (cb *Chessboard) IsFivefoldRepetition() bool {
+	return cb.Repetitions() >= 5
+}
+
+// IsFiftyMoveDraw returns true if 50 moves have passed by each side without a capture or pawn move, which allows a
+// draw to be claimed.
+func (cb *Chessboard) IsFiftyMoveDraw() bool {
+	return cb.HalfMoveClock >= 100
+}
+
+// IsSeventyFiveMoveDraw returns true if 75 moves have passed by each side without a capture or pawn move, which
+// draws the game.
+func (cb *Chessboard) IsSeventyFiveMoveDraw() bool {
+	return cb.HalfMoveClock >= 150
+}
+
+// IsInsufficientMaterial returns true if neither side could possibly checkmate: kings with at most a single knight,
+// or kings and bishops which are all on the same colour square. It's always false when playing a variant, which can
+// be won in other ways.
+func (cb *Chessboard) IsInsufficientMaterial() bool {
+	if cb.Variant != nil {
+		return false
+	}
+	var knights, bishops int
+	bishopColours := [2]bool{}
+	for y := range cb.Board {
+		for x, p := range cb.Board[y] {
+			switch p {
+			case 0, WhiteKing, BlackKing:
+			case WhiteKnight, BlackKnight:
+				knights++
+			case WhiteBishop, BlackBishop:
+				bishops++
+				bishopColours[(x+y)%2] = true
+			default:
+				return false
+			}
+		}
+	}
+	if knights > 0 {
+		return knights == 1 && bishops == 0
+	}
+	return !(bishopColours[0] && bishopColours[1])
+}
+
+// HasMatingMaterial returns false if the colour has only its king, or its king and a single knight or bishop, so a
+// player running out of time with it as their opponent draws rather than loses. It's always true when playing a
+// variant, which can be won in other ways.
+func (cb *Chessboard) HasMatingMaterial(black bool) bool {
+	if cb.Variant != nil {
+		return true
+	}
+	minors := 0
+	for y := range cb.Board {
+		for _, p := range cb.Board[y] {
+			if !isPiece(p) || IsBlack(p) != black {
+				continue
+			}
+			switch p {
+			case WhiteKing, BlackKing:
+			case WhiteKnight, BlackKnight, WhiteBishop, BlackBishop:
+				minors++
+			default:
+				return true
+			}
+		}
+	}
+	return minors > 1
+}
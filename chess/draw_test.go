@@ -0,0 +1,120 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMoveRuleDraws(t *testing.T) {
+	for _, test := range []struct {
+		clock              int
+		fifty, seventyFive bool
+	}{
+		{0, false, false},
+		{99, false, false},
+		{100, true, false},
+		{149, true, false},
+		{150, true, true},
+	} {
+		cb, err := ParseFEN(fmt.Sprintf("4k3/8/8/8/8/8/8/4K2R w - - %d 80", test.clock))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cb.IsFiftyMoveDraw() != test.fifty || cb.IsSeventyFiveMoveDraw() != test.seventyFive {
+			t.Errorf("clock %d: IsFiftyMoveDraw(), IsSeventyFiveMoveDraw() = %v, %v, want %v, %v", test.clock,
+				cb.IsFiftyMoveDraw(), cb.IsSeventyFiveMoveDraw(), test.fifty, test.seventyFive)
+		}
+	}
+
+	// the clock reaches the threshold by a move, and a pawn move resets it
+	cb, err := ParseFEN("4k3/8/8/8/8/8/P7/4K2R w - - 99 80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.DoSAN("Rh2"); err != nil {
+		t.Fatal(err)
+	}
+	if !cb.IsFiftyMoveDraw() {
+		t.Errorf("IsFiftyMoveDraw() = false with the clock at %d", cb.HalfMoveClock)
+	}
+	if err := cb.DoSAN("Kd7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.DoSAN("a3"); err != nil {
+		t.Fatal(err)
+	}
+	if cb.IsFiftyMoveDraw() {
+		t.Errorf("IsFiftyMoveDraw() = true after a pawn move, with the clock at %d", cb.HalfMoveClock)
+	}
+}
+
+func TestRepetition(t *testing.T) {
+	const shuffle = "Kf1 Kf8 Ke1 Ke8"
+	for _, test := range []struct {
+		name, fen, moves string
+		want             int
+	}{
+		{"start", StartingFEN, "", 1},
+		{"knights", StartingFEN, "Nf3 Nf6 Ng1 Ng8", 2},
+		{"knights twice", StartingFEN, "Nf3 Nf6 Ng1 Ng8 Nf3 Nf6 Ng1 Ng8", 3},
+		{"knights four times", StartingFEN, strings.Repeat("Nf3 Nf6 Ng1 Ng8 ", 4), 5},
+		{"other side to move", StartingFEN, "Nf3 Nf6 Ng1 Ng8 Nf3", 2},
+		// an en passant capture which could be made changes the position, one which couldn't doesn't
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", shuffle, 1},
+		{"en passant twice", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", shuffle + " " + shuffle, 2},
+		{"no en passant", "4k3/8/8/3p4/8/8/8/4K3 w - d6 0 2", shuffle, 2},
+		// losing castling rights changes the position
+		{"castling", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", shuffle, 1},
+		{"castling twice", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", shuffle + " " + shuffle, 2},
+		{"no castling", "r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1", shuffle, 2},
+		// positions before a pawn move can't repeat
+		{"pawn move", StartingFEN, "Nf3 Nf6 Ng1 Ng8 e4 e5 Nf3 Nf6 Ng1 Ng8", 2},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, san := range strings.Fields(test.moves) {
+			if err := cb.DoSAN(san); err != nil {
+				t.Fatalf("%s: DoSAN(%q): %v", test.name, san, err)
+			}
+		}
+		if n := cb.Repetitions(); n != test.want {
+			t.Errorf("%s: Repetitions() = %d, want %d", test.name, n, test.want)
+		}
+		if cb.IsThreefoldRepetition() != (test.want >= 3) || cb.IsFivefoldRepetition() != (test.want >= 5) {
+			t.Errorf("%s: IsThreefoldRepetition(), IsFivefoldRepetition() = %v, %v after %d repetitions", test.name,
+				cb.IsThreefoldRepetition(), cb.IsFivefoldRepetition(), test.want)
+		}
+	}
+}
+
+func TestInsufficientMaterial(t *testing.T) {
+	for _, test := range []struct {
+		name, fen string
+		want      bool
+	}{
+		{"kings", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"bishop", "4k3/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{"knight", "4k3/8/8/8/8/8/8/1N2K3 w - - 0 1", true},
+		{"black knight", "1n2k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"same colour bishops", "4kb2/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{"same side bishops", "4k3/8/8/8/8/8/8/2B1KB1B w - - 0 1", false},
+		{"opposite colour bishops", "2b1k3/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"two knights", "4k3/8/8/8/8/8/8/1N2K1N1 w - - 0 1", false},
+		{"knight and bishop", "1n2k3/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"pawn", "4k3/8/8/8/8/8/P7/4K3 w - - 0 1", false},
+		{"rook", "4k3/8/8/8/8/8/8/4K2R w - - 0 1", false},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cb.IsInsufficientMaterial(); got != test.want {
+			t.Errorf("%s: IsInsufficientMaterial() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
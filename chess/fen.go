@@ -0,0 +1,381 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StartingFEN is the standard starting position in Forsyth-Edwards Notation.
+const StartingFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// pieceLetters maps pieces to their FEN letters, uppercase for white and lowercase for black.
+var pieceLetters = map[Piece]byte{
+	WhiteKing: 'K', WhiteQueen: 'Q', WhiteRook: 'R', WhiteBishop: 'B', WhiteKnight: 'N', WhitePawn: 'P',
+	BlackKing: 'k', BlackQueen: 'q', BlackRook: 'r', BlackBishop: 'b', BlackKnight: 'n', BlackPawn: 'p',
+}
+
+// PieceFromLetter returns the piece represented by a FEN letter, or 0 if it isn't one.
+func PieceFromLetter(l byte) Piece {
+	for p, letter := range pieceLetters {
+		if letter == l {
+			return p
+		}
+	}
+	return 0
+}
+
+// Letter returns the FEN letter of a piece, or 0 if p isn't a piece.
+func Letter(p Piece) byte {
+	return pieceLetters[p]
+}
+
+// squareName returns the algebraic name of a board coordinate (ie: "e4").
+func squareName(x, y int8) string {
+	return string([]byte{'a' + byte(x), '8' - byte(y)})
+}
+
+// parseSquare parses an algebraic square name (ie: "e4") into board coordinates.
+func parseSquare(s string) (x, y int8, ok bool) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return 0, 0, false
+	}
+	return int8(s[0] - 'a'), int8('8' - s[1]), true
+}
+
+// ParseFEN parses a position in Forsyth-Edwards Notation. The halfmove clock and fullmove number may be omitted, in
+// which case they default to 0 and 1. A position with pockets (ie: "[Qn]" after the pieces, or a non-empty ninth
+// rank) is played as Crazyhouse, and its promoted pieces may be marked with "~".
+func ParseFEN(fen string) (cb *Chessboard, err error) {
+	fields := strings.Fields(fen)
+	if len(fields) != 4 && len(fields) != 6 {
+		err = fmt.Errorf("FEN has %d fields, expected 6", len(fields))
+		return
+	}
+	board := new(Chessboard)
+
+	// Crazyhouse pockets, in brackets after the pieces or as a ninth rank
+	placement, pocket, hasPocket := fields[0], "", false
+	if i := strings.IndexByte(placement, '['); i >= 0 {
+		if !strings.HasSuffix(placement, "]") {
+			err = fmt.Errorf("FEN pocket %q is missing its closing bracket", placement[i:])
+			return
+		}
+		placement, pocket, hasPocket = placement[:i], placement[i+1:len(placement)-1], true
+	} else if strings.Count(placement, "/") == 8 {
+		i := strings.LastIndexByte(placement, '/')
+		if i == len(placement)-1 {
+			err = fmt.Errorf("FEN has an empty ninth rank, expected a pocket")
+			return
+		}
+		placement, pocket, hasPocket = placement[:i], placement[i+1:], true
+	}
+
+	// piece placement
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		err = fmt.Errorf("FEN piece placement has %d ranks, expected 8", len(ranks))
+		return
+	}
+	for y, rank := range ranks {
+		x := 0
+		for i := 0; i < len(rank); i++ {
+			c := rank[i]
+			if c >= '1' && c <= '8' {
+				x += int(c - '0')
+				continue
+			}
+			if c == '~' {
+				if x == 0 || board.Board[y][x-1] == 0 {
+					err = fmt.Errorf("FEN rank %d marks a promotion without a piece", 8-y)
+					return
+				}
+				board.promoted |= bit(int8(x-1), int8(y))
+				continue
+			}
+			p := PieceFromLetter(c)
+			if p == 0 {
+				err = fmt.Errorf("FEN rank %d has invalid piece %q", 8-y, c)
+				return
+			}
+			if x > 7 {
+				err = fmt.Errorf("FEN rank %d describes more than 8 squares", 8-y)
+				return
+			}
+			board.Board[y][x] = p
+			x++
+		}
+		if x != 8 {
+			err = fmt.Errorf("FEN rank %d describes %d squares, expected 8", 8-y, x)
+			return
+		}
+	}
+
+	if hasPocket {
+		board.Variant = Crazyhouse{}
+		for i := 0; i < len(pocket); i++ {
+			p := PieceFromLetter(pocket[i])
+			if p == 0 || p == WhiteKing || p == BlackKing {
+				err = fmt.Errorf("FEN pocket has invalid piece %q", pocket[i])
+				return
+			}
+			c, slot := pocketSlot(p)
+			if board.pockets[c][slot] == maxPocket {
+				err = fmt.Errorf("FEN pocket has too many %q", pocket[i])
+				return
+			}
+			board.pockets[c][slot]++
+		}
+	}
+
+	// side to move
+	switch fields[1] {
+	case "w":
+	case "b":
+		board.BlackMove = true
+	default:
+		err = fmt.Errorf("FEN side to move is %q, expected \"w\" or \"b\"", fields[1])
+		return
+	}
+
+	// castling rights, as KQkq or the files of the rooks (Shredder-FEN and X-FEN)
+	board.WhiteCantCastleLeft, board.WhiteCantCastleRight = true, true
+	board.BlackCantCastleLeft, board.BlackCantCastleRight = true, true
+	board.RookFiles = [2][2]int8{{0, 7}, {0, 7}}
+	if fields[2] != "-" {
+		for i := 0; i < len(fields[2]); i++ {
+			c := fields[2][i]
+			black := c >= 'a'
+			kingX := board.homeKing(black)
+			var (
+				left bool
+				file int8
+			)
+			switch c {
+			case 'K', 'k':
+				file = board.outerRook(black, kingX, false)
+			case 'Q', 'q':
+				left = true
+				file = board.outerRook(black, kingX, true)
+			case 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h':
+				if kingX < 0 {
+					err = fmt.Errorf("FEN castling rights %q need a king on its first rank", fields[2])
+					return
+				}
+				file = int8(c|0x20) - 'a'
+				left = file < kingX
+			default:
+				err = fmt.Errorf("FEN castling rights %q contain invalid character %q", fields[2], c)
+				return
+			}
+			flag := board.cantCastle(black, left)
+			if !*flag {
+				err = fmt.Errorf("FEN castling rights %q repeat %q", fields[2], c)
+				return
+			}
+			*flag = false
+			side := 1
+			if left {
+				side = 0
+			}
+			board.RookFiles[colour(black)][side] = file
+			// castling from anywhere but the usual squares needs Chess960 rules
+			if (kingX >= 0 && kingX != 4) || file != int8(side*7) {
+				board.Chess960 = true
+			}
+		}
+	}
+
+	// en passant target, stored as the position of the pawn that can be taken
+	if fields[3] != "-" {
+		x, y, ok := parseSquare(fields[3])
+		if !ok {
+			err = fmt.Errorf("FEN en passant square %q is not a square", fields[3])
+			return
+		}
+		if (board.BlackMove && y != 5) || (!board.BlackMove && y != 2) {
+			err = fmt.Errorf("FEN en passant square %s is on the wrong rank for the side to move", fields[3])
+			return
+		}
+		pawn, py := BlackPawn, y+1
+		if board.BlackMove {
+			pawn, py = WhitePawn, y-1
+		}
+		if board.Board[py][x] != pawn {
+			err = fmt.Errorf("FEN en passant square %s has no pawn in front of it", fields[3])
+			return
+		}
+		board.CanBeEnPassant = &[2]int8{x, py}
+	}
+
+	// move counters
+	board.FullMoveNumber = 1
+	if len(fields) == 6 {
+		board.HalfMoveClock, err = strconv.Atoi(fields[4])
+		if err != nil || board.HalfMoveClock < 0 {
+			err = fmt.Errorf("FEN halfmove clock %q is not a non-negative number", fields[4])
+			return
+		}
+		board.FullMoveNumber, err = strconv.Atoi(fields[5])
+		if err != nil || board.FullMoveNumber < 1 {
+			err = fmt.Errorf("FEN fullmove number %q is not a positive number", fields[5])
+			return
+		}
+	}
+
+	board.history = make([]uint64, 1)
+	board.Sync()
+	cb = board
+	return
+}
+
+// homeKing returns the file of the colour's king if it's on its first rank, or -1.
+func (cb *Chessboard) homeKing(black bool) int8 {
+	y, king := 7, WhiteKing
+	if black {
+		y, king = 0, BlackKing
+	}
+	for x, p := range cb.Board[y] {
+		if p == king {
+			return int8(x)
+		}
+	}
+	return -1
+}
+
+// outerRook returns the file of the colour's rook on its first rank furthest from the king on the specified side,
+// or the corner if there isn't one.
+func (cb *Chessboard) outerRook(black bool, kingX int8, left bool) int8 {
+	y, rook := 7, WhiteRook
+	if black {
+		y, rook = 0, BlackRook
+	}
+	if left {
+		for x := int8(0); x < kingX; x++ {
+			if cb.Board[y][x] == rook {
+				return x
+			}
+		}
+		return 0
+	}
+	for x := int8(7); x > kingX && kingX >= 0; x-- {
+		if cb.Board[y][x] == rook {
+			return x
+		}
+	}
+	return 7
+}
+
+// castleLetter returns the letter giving a castling right in FEN: KQkq, or the rook's file if shredder is true or
+// the rook isn't the outermost one (as in X-FEN).
+func (cb *Chessboard) castleLetter(black, left, shredder bool) byte {
+	file := cb.CastleFile(black, left)
+	var l byte
+	switch {
+	case shredder || file != cb.outerRook(black, cb.homeKing(black), left):
+		l = 'A' + byte(file)
+	case left:
+		l = 'Q'
+	default:
+		l = 'K'
+	}
+	if black {
+		l |= 0x20
+	}
+	return l
+}
+
+// FEN returns the position in Forsyth-Edwards Notation. Chess960 castling rights are written as in X-FEN, and
+// Crazyhouse pockets in brackets after the pieces.
+func (cb *Chessboard) FEN() string {
+	return cb.fen(false)
+}
+
+// ShredderFEN returns the position in Shredder-FEN, which is FEN with castling rights given by the files of the
+// rooks (ie: "HAha").
+func (cb *Chessboard) ShredderFEN() string {
+	return cb.fen(true)
+}
+
+// pocketLetters returns the FEN letters of the pieces in both pockets, white's then black's (ie: "QNPp").
+func (cb *Chessboard) pocketLetters() string {
+	var s string
+	for _, black := range [2]bool{false, true} {
+		for _, p := range promotions[colour(black)] {
+			s += strings.Repeat(string(Letter(p)), cb.Pocket(p))
+		}
+		pawn := WhitePawn
+		if black {
+			pawn = BlackPawn
+		}
+		s += strings.Repeat(string(Letter(pawn)), cb.Pocket(pawn))
+	}
+	return s
+}
+
+func (cb *Chessboard) fen(shredder bool) string {
+	var sb strings.Builder
+	_, crazyhouse := cb.Variant.(Crazyhouse)
+	for y := range cb.Board {
+		if y > 0 {
+			sb.WriteByte('/')
+		}
+		empty := 0
+		for x, p := range cb.Board[y] {
+			if p == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte('0' + byte(empty))
+				empty = 0
+			}
+			sb.WriteByte(Letter(p))
+			if crazyhouse && cb.promoted&bit(int8(x), int8(y)) != 0 {
+				sb.WriteByte('~')
+			}
+		}
+		if empty > 0 {
+			sb.WriteByte('0' + byte(empty))
+		}
+	}
+	if crazyhouse {
+		sb.WriteString("[" + cb.pocketLetters() + "]")
+	}
+
+	if cb.BlackMove {
+		sb.WriteString(" b ")
+	} else {
+		sb.WriteString(" w ")
+	}
+
+	castling := ""
+	for _, black := range [2]bool{false, true} {
+		for _, left := range [2]bool{false, true} {
+			if !*cb.cantCastle(black, left) {
+				castling += string(cb.castleLetter(black, left, shredder))
+			}
+		}
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	sb.WriteString(castling)
+
+	if cb.CanBeEnPassant != nil {
+		x, y := cb.CanBeEnPassant[0], cb.CanBeEnPassant[1]
+		if IsBlack(cb.Board[y][x]) {
+			y--
+		} else {
+			y++
+		}
+		sb.WriteString(" " + squareName(x, y) + " ")
+	} else {
+		sb.WriteString(" - ")
+	}
+
+	sb.WriteString(strconv.Itoa(cb.HalfMoveClock) + " " + strconv.Itoa(cb.FullMoveNumber))
+	return sb.String()
+}
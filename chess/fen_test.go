@@ -0,0 +1,137 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+func TestFENRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		fen, shredder string
+	}{
+		{StartingFEN, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+			"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w HAha - 0 1"},
+		{"rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3",
+			"rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR w HAha d6 0 3"},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b Kq e3 0 1",
+			"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b Ha e3 0 1"},
+		{"8/2k5/8/8/8/8/5K2/8 b - - 47 120", "8/2k5/8/8/8/8/5K2/8 b - - 47 120"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[QNPp] w KQkq - 0 1",
+			"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[QNPp] w HAha - 0 1"},
+		{"rnbqkb1r/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBQ~R[] b KQkq - 0 9",
+			"rnbqkb1r/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBQ~R[] b HAha - 0 9"},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Errorf("ParseFEN(%q): %v", test.fen, err)
+			continue
+		}
+		if fen := cb.FEN(); fen != test.fen {
+			t.Errorf("FEN() = %q, want %q", fen, test.fen)
+		}
+		if fen := cb.ShredderFEN(); fen != test.shredder {
+			t.Errorf("ShredderFEN() = %q, want %q", fen, test.shredder)
+		}
+		again, err := ParseFEN(test.shredder)
+		if err != nil {
+			t.Errorf("ParseFEN(%q): %v", test.shredder, err)
+		} else if fen := again.FEN(); fen != test.fen {
+			t.Errorf("ParseFEN(%q).FEN() = %q, want %q", test.shredder, fen, test.fen)
+		}
+	}
+}
+
+func TestFENDefaults(t *testing.T) {
+	cb, err := ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cb.FEN() != StartingFEN || cb.HalfMoveClock != 0 || cb.FullMoveNumber != 1 {
+		t.Errorf("FEN() = %q, want %q", cb.FEN(), StartingFEN)
+	}
+
+	// a ninth rank is a Crazyhouse pocket, written back in brackets
+	cb, err = ParseFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/Qn w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cb.Variant.(Crazyhouse); !ok || cb.Pocket(WhiteQueen) != 1 || cb.Pocket(BlackKnight) != 1 {
+		t.Errorf("ninth rank pocket gave %s", cb.FEN())
+	}
+	if want := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[Qn] w KQkq - 0 1"; cb.FEN() != want {
+		t.Errorf("FEN() = %q, want %q", cb.FEN(), want)
+	}
+}
+
+func TestXFEN(t *testing.T) {
+	for _, test := range []struct {
+		fen, want, shredder string
+		files               [2][2]int8
+	}{
+		// the outermost rook is written as K or Q, an inner one by its file
+		{"rk4rr/8/8/8/8/8/8/RK4RR w KQkq - 0 1", "rk4rr/8/8/8/8/8/8/RK4RR w KQkq - 0 1",
+			"rk4rr/8/8/8/8/8/8/RK4RR w HAha - 0 1", [2][2]int8{{0, 7}, {0, 7}}},
+		{"rk4rr/8/8/8/8/8/8/RK4RR w Gg - 0 1", "rk4rr/8/8/8/8/8/8/RK4RR w Gg - 0 1",
+			"rk4rr/8/8/8/8/8/8/RK4RR w Gg - 0 1", [2][2]int8{{0, 6}, {0, 6}}},
+		{"rk4rr/8/8/8/8/8/8/RK4RR w HAha - 0 1", "rk4rr/8/8/8/8/8/8/RK4RR w KQkq - 0 1",
+			"rk4rr/8/8/8/8/8/8/RK4RR w HAha - 0 1", [2][2]int8{{0, 7}, {0, 7}}},
+		{"1r1k2r1/8/8/8/8/8/8/1R1K2R1 w KQkq - 0 1", "1r1k2r1/8/8/8/8/8/8/1R1K2R1 w KQkq - 0 1",
+			"1r1k2r1/8/8/8/8/8/8/1R1K2R1 w GBgb - 0 1", [2][2]int8{{1, 6}, {1, 6}}},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Errorf("ParseFEN(%q): %v", test.fen, err)
+			continue
+		}
+		if !cb.Chess960 || cb.RookFiles != test.files {
+			t.Errorf("%s: Chess960, RookFiles = %v, %v, want true, %v", test.fen, cb.Chess960, cb.RookFiles, test.files)
+		}
+		if fen := cb.FEN(); fen != test.want {
+			t.Errorf("%s: FEN() = %q, want %q", test.fen, fen, test.want)
+		}
+		if fen := cb.ShredderFEN(); fen != test.shredder {
+			t.Errorf("%s: ShredderFEN() = %q, want %q", test.fen, fen, test.shredder)
+		}
+	}
+}
+
+func TestParseFENErrors(t *testing.T) {
+	for _, fen := range []string{
+		// field counts
+		"",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 1",
+		// ranks
+		"rnbqkbnr/pppppppp/8/8/8/8/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/ppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/4x3/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/~8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[Qn w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[K] w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/ w KQkq - 0 1",
+		// side to move
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1",
+		// castling
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkqK - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KX - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAH - 0 1",
+		"4k3/8/8/8/8/4K3/8/8 w A - 0 1",
+		// en passant
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e9 0 1",
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e4 0 1",
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e3 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq d3 0 1",
+		// clocks
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - x 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 one",
+	} {
+		if cb, err := ParseFEN(fen); err == nil {
+			t.Errorf("ParseFEN(%q) = %s, want an error", fen, cb.FEN())
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+// Run a target with ie: go test -run - -fuzz FuzzParseFEN ./chess
+
+func FuzzParseFEN(f *testing.F) {
+	for _, pos := range perftPositions {
+		f.Add(pos.fen)
+	}
+	for _, pos := range propertyPositions {
+		f.Add(pos.fen)
+	}
+	f.Add("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR/Qn w KQkq - 0 1")
+	f.Add("r~nbqkbnr/8/8/8/8/8/8/RNBQKBNR w AHah e3 0 1")
+	f.Fuzz(func(t *testing.T, fen string) {
+		cb, err := ParseFEN(fen)
+		if err != nil {
+			return
+		}
+		out := cb.FEN()
+		again, err := ParseFEN(out)
+		if err != nil {
+			t.Fatalf("ParseFEN(%q) can't parse its own FEN %q: %v", fen, out, err)
+		}
+		if again.FEN() != out {
+			t.Fatalf("ParseFEN(%q) gave %q, which round trips to %q", fen, out, again.FEN())
+		}
+		cb.Validate()
+		for _, m := range cb.LegalMoves(cb.BlackMove) {
+			cb.Make(m)
+			cb.Unmake(m)
+		}
+		if cb.FEN() != out {
+			t.Fatalf("%s: position is %s after making and taking back every move", out, cb.FEN())
+		}
+	})
+}
+
+func FuzzMoves(f *testing.F) {
+	for _, pos := range perftPositions {
+		f.Add(pos.fen, byte(0), []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	}
+	for i, pos := range propertyPositions {
+		f.Add(pos.fen, byte(i+1), []byte{9, 8, 7, 6, 5, 4, 3, 2, 1})
+	}
+	f.Fuzz(func(t *testing.T, fen string, variant byte, path []byte) {
+		cb, err := ParseFEN(fen)
+		if err != nil || cb.Validate() != nil || len(path) > 64 {
+			return
+		}
+		switch variant % 6 {
+		case 1:
+			cb.Variant = KingOfTheHill{}
+		case 2:
+			cb.Variant = ThreeCheck{}
+		case 3:
+			cb.Variant = Atomic{}
+		case 4:
+			cb.Variant = Crazyhouse{}
+		case 5:
+			cb.Variant = Antichess{}
+		}
+		// play a line of legal moves, chosen by path
+		for _, choice := range path {
+			moves := cb.LegalMoves(cb.BlackMove)
+			if len(moves) == 0 {
+				return
+			}
+			m := moves[int(choice)%len(moves)]
+			checkMove(t, cb, m)
+			cb.Make(m)
+		}
+	})
+}
+
+func FuzzDoMove(f *testing.F) {
+	f.Add(StartingFEN, int8(4), int8(6), int8(4), int8(4), int8(0))
+	f.Add("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", int8(4), int8(7), int8(4), int8(7), int8(2))
+	f.Add("4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", int8(4), int8(3), int8(3), int8(3), int8(1))
+	f.Add("4k3/P7/8/8/8/8/8/4K3[N] w - - 0 1", int8(0), int8(1), int8(0), int8(0), int8(4))
+	f.Fuzz(func(t *testing.T, fen string, fx, fy, tx, ty, movet int8) {
+		cb, err := ParseFEN(fen)
+		if err != nil {
+			return
+		}
+		from, to, mt := [2]int8{fx, fy}, [2]int8{tx, ty}, MoveType(movet)
+
+		// none of these should panic, whatever the coordinates
+		cb.IsLegal(from, to, mt)
+		cb.PossibleMoves(Square(fx))
+		cb.PossibleThreats(fx, fy)
+		cb.SAN(from, to, mt, 0)
+		cb.TestMove(from, to)
+		cb.TestEnPassant(from, to)
+		cb.TestCastle(from, movet%2 == 0)
+		cb.CanDrop(WhiteKnight, to)
+		cb.Attackers(Square(tx), movet%2 == 0)
+		cb.SEE(Square(tx), movet%2 == 0)
+		cb.Copy().PromotePawn(tx, ty, WhiteQueen)
+		cb.Copy().DoMove(from, to)
+		cb.Copy().DoEnPassant(from, to)
+		cb.Copy().DoCastle(from, movet%2 == 0)
+		cb.Copy().DoDrop(WhiteKnight, to)
+
+		// a legal move must be playable, as the server plays them
+		if cb.Validate() != nil || !cb.IsLegal(from, to, mt) {
+			return
+		}
+		g := NewGame(cb)
+		black := g.BlackMove
+		switch mt {
+		case RegularMove:
+			g.DoMove(from, to)
+		case EnPassant:
+			g.DoEnPassant(from, to)
+		case CastleLeft, CastleRight:
+			g.DoCastle(from, mt == CastleLeft)
+		}
+		if len(g.Moves()) != 1 {
+			t.Fatalf("%s: legal move %v %v %v wasn't played", fen, from, to, mt)
+		}
+		if g.kingAttacked(black) {
+			t.Fatalf("%s: legal move %v %v %v leaves the king in check", fen, from, to, mt)
+		}
+	})
+}
@@ -0,0 +1,274 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Termination is why a game ended.
+type Termination int
+
+const (
+	NotTerminated Termination = iota
+	Checkmate
+	Stalemate
+	VariantRules // won by the variant's own rules, ie: reaching the centre in King of the Hill
+	InsufficientMaterial
+	FiftyMoveRule
+	SeventyFiveMoveRule
+	ThreefoldRepetition
+	FivefoldRepetition
+	Resignation
+	Timeout
+)
+
+// String returns a readable description of the termination (ie: "threefold repetition").
+func (t Termination) String() string {
+	switch t {
+	case Checkmate:
+		return "checkmate"
+	case Stalemate:
+		return "stalemate"
+	case VariantRules:
+		return "the variant's rules"
+	case InsufficientMaterial:
+		return "insufficient material"
+	case FiftyMoveRule:
+		return "the fifty-move rule"
+	case SeventyFiveMoveRule:
+		return "the seventy-five-move rule"
+	case ThreefoldRepetition:
+		return "threefold repetition"
+	case FivefoldRepetition:
+		return "fivefold repetition"
+	case Resignation:
+		return "resignation"
+	case Timeout:
+		return "timeout"
+	}
+	return "not terminated"
+}
+
+// Game is a game being played: its current position, and every move made to reach it. Moves made through the Game's
+// methods are recorded, while those made directly on its Chessboard (ie: with Make) aren't, and must be undone before
+// it's used again.
+type Game struct {
+	*Chessboard // the position being viewed, which is the latest unless GoTo moved back
+
+	Result      Result      // Unfinished until the game ends
+	Termination Termination // why the game ended
+
+	start *Chessboard
+	moves []Move
+	ply   int // number of moves made to reach Chessboard
+}
+
+// NewGame starts a game from a position, which should already have its Variant set. The game plays on the position,
+// so it shouldn't be changed elsewhere.
+func NewGame(start *Chessboard) *Game {
+	g := &Game{Chessboard: start, start: start.Copy()}
+	g.update()
+	return g
+}
+
+// Moves returns every move made in the game, including any after the position being viewed.
+func (g *Game) Moves() []Move {
+	return g.moves
+}
+
+// Ply returns the number of moves made to reach the position being viewed.
+func (g *Game) Ply() int {
+	return g.ply
+}
+
+// Position returns a copy of the position once ply moves were made, the starting position being ply 0. Returns nil if
+// the game doesn't have that many moves.
+func (g *Game) Position(ply int) *Chessboard {
+	if ply < 0 || ply > len(g.moves) {
+		return nil
+	}
+	cb := g.start.Copy()
+	for _, m := range g.moves[:ply] {
+		cb.Make(m)
+	}
+	return cb
+}
+
+// GoTo moves the game to the position once ply moves were made, the starting position being ply 0, and returns true.
+// Making a move from an earlier position replaces the moves after it. Returns false if the game doesn't have that
+// many moves.
+func (g *Game) GoTo(ply int) bool {
+	if ply < 0 || ply > len(g.moves) {
+		return false
+	}
+	for ; g.ply > ply; g.ply-- {
+		g.Unmake(g.moves[g.ply-1])
+	}
+	for ; g.ply < ply; g.ply++ {
+		g.Make(g.moves[g.ply])
+	}
+	return true
+}
+
+// play makes and records a move, returning true if it puts the opposing player in check. Does nothing once the game
+// has ended, or while a pawn is waiting to be promoted.
+func (g *Game) play(m Move) bool {
+	if g.Result != Unfinished || g.promotionPending() {
+		return false
+	}
+	g.moves = append(g.moves[:g.ply], m)
+	g.ply++
+	check := g.Make(m)
+	g.update()
+	return check
+}
+
+// Performs a move and returns true if a move would result in a check for the opposing player. Does nothing if it's
+// not the moving piece's turn.
+func (g *Game) DoMove(from, to [2]int8) bool {
+	if !g.isTurn(from) || !onBoard(to) {
+		return false
+	}
+	return g.play(g.NewMove(from, to, RegularMove, 0))
+}
+
+// Performs a move and returns true if a move would result in a check for the opposing player. Does nothing if it's
+// not the moving piece's turn.
+func (g *Game) DoEnPassant(from, to [2]int8) bool {
+	if !g.isEnPassant(from, to) {
+		return false
+	}
+	return g.play(g.NewMove(from, to, EnPassant, 0))
+}
+
+// Performs a move and returns true if a move would result in a check for the opposing player. Does nothing if it's
+// not the king's turn.
+func (g *Game) DoCastle(from [2]int8, left bool) bool {
+	if !g.isTurn(from) {
+		return false
+	}
+	if left {
+		return g.play(g.NewMove(from, from, CastleLeft, 0))
+	}
+	return g.play(g.NewMove(from, from, CastleRight, 0))
+}
+
+// DoDrop drops a piece from the side to move's pocket and returns true if it puts the opposing player in check. Does
+// nothing if the drop isn't legal.
+func (g *Game) DoDrop(p Piece, to [2]int8) bool {
+	if !g.CanDrop(p, to) {
+		return false
+	}
+	return g.play(g.NewMove(to, to, Drop, p))
+}
+
+// DoSAN performs a move in Standard Algebraic Notation made by the side to move, including any promotion. Returns an
+// error if the move can't be played.
+func (g *Game) DoSAN(san string) error {
+	if g.Result != Unfinished {
+		return errors.New("Game is over")
+	}
+	if g.promotionPending() {
+		return errors.New("A pawn is waiting to be promoted")
+	}
+	from, to, movet, promotion, err := g.ParseSAN(san)
+	if err != nil {
+		return err
+	}
+	ply := g.ply
+	g.play(g.NewMove(from, to, movet, promotion))
+	if g.ply == ply {
+		return fmt.Errorf("Move %q wasn't played", san)
+	}
+	return nil
+}
+
+// PromotePawn promotes the pawn which just reached the last rank at x, y, returns true on success, and false on
+// failure.
+func (g *Game) PromotePawn(x, y int8, to Piece) bool {
+	if !g.promotionPending() || g.moves[g.ply-1].To != [2]int8{x, y} || !g.Chessboard.PromotePawn(x, y, to) {
+		return false
+	}
+	g.moves[g.ply-1].Promotion = to
+	g.update()
+	return true
+}
+
+// promotionPending returns true if the last move took a pawn to the last rank without promoting it, and it's the
+// latest position.
+func (g *Game) promotionPending() bool {
+	if g.ply == 0 || g.ply != len(g.moves) {
+		return false
+	}
+	m := g.moves[g.ply-1]
+	if m.Type != RegularMove || m.Promotion != 0 || (m.To[1] != 0 && m.To[1] != 7) {
+		return false
+	}
+	p := g.Board[m.To[1]][m.To[0]]
+	return p == WhitePawn || p == BlackPawn
+}
+
+// end ends the game.
+func (g *Game) end(r Result, t Termination) {
+	g.Result, g.Termination = r, t
+}
+
+// update ends the game if the latest position is won, lost or drawn.
+func (g *Game) update() {
+	if g.promotionPending() {
+		return
+	}
+	black := g.BlackMove
+	switch r := g.VariantResult(); {
+	case r != Unfinished:
+		g.end(r, VariantRules)
+	case g.IsCheckmate(black):
+		g.end(winner(!black), Checkmate)
+	case g.IsStalemate(black):
+		g.end(Draw, Stalemate)
+	case g.IsInsufficientMaterial():
+		g.end(Draw, InsufficientMaterial)
+	case g.IsFivefoldRepetition():
+		g.end(Draw, FivefoldRepetition)
+	case g.IsSeventyFiveMoveDraw():
+		g.end(Draw, SeventyFiveMoveRule)
+	}
+}
+
+// ClaimDraw draws the game by threefold repetition or the fifty-move rule if either allows it, and returns true if
+// it did.
+func (g *Game) ClaimDraw() bool {
+	if g.Result != Unfinished || g.ply != len(g.moves) {
+		return false
+	}
+	switch {
+	case g.IsThreefoldRepetition():
+		g.end(Draw, ThreefoldRepetition)
+	case g.IsFiftyMoveDraw():
+		g.end(Draw, FiftyMoveRule)
+	default:
+		return false
+	}
+	return true
+}
+
+// Resign ends the game with the colour resigning, unless it has already ended.
+func (g *Game) Resign(black bool) {
+	if g.Result == Unfinished {
+		g.end(winner(!black), Resignation)
+	}
+}
+
+// Timeout ends the game with the colour running out of time, unless it has already ended. The colour loses, or draws
+// if its opponent couldn't checkmate.
+func (g *Game) Timeout(black bool) {
+	switch {
+	case g.Result != Unfinished:
+	case g.HasMatingMaterial(!black):
+		g.end(winner(!black), Timeout)
+	default:
+		g.end(Draw, Timeout)
+	}
+}
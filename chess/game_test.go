@@ -0,0 +1,127 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+func TestGame(t *testing.T) {
+	g := NewGame(NewChessboard())
+	for _, san := range []string{"f3", "e5", "g4", "Qh4#"} {
+		if err := g.DoSAN(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.Result != BlackWins || g.Termination != Checkmate {
+		t.Errorf("Result, Termination = %v, %v, want black wins by checkmate", g.Result, g.Termination)
+	}
+	if err := g.DoSAN("a3"); err == nil {
+		t.Error("DoSAN() made a move after checkmate")
+	}
+	if len(g.Moves()) != 4 || g.Ply() != 4 || g.Moves()[3].String() != "d8h4" {
+		t.Errorf("Moves() = %v at ply %d", g.Moves(), g.Ply())
+	}
+
+	final := g.FEN()
+	if !g.GoTo(2) || g.Ply() != 2 || g.FEN() != g.Position(2).FEN() {
+		t.Fatalf("GoTo(2) reached %s, want %s", g.FEN(), g.Position(2).FEN())
+	}
+	if g.Position(0).FEN() != StartingFEN || g.Position(5) != nil || g.GoTo(-1) {
+		t.Error("Position or GoTo accepted a ply the game doesn't have")
+	}
+	if !g.GoTo(4) || g.FEN() != final {
+		t.Errorf("GoTo(4) reached %s, want %s", g.FEN(), final)
+	}
+}
+
+func TestGameReplacesMoves(t *testing.T) {
+	g := NewGame(NewChessboard())
+	for _, san := range []string{"e4", "e5", "Nf3"} {
+		if err := g.DoSAN(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.GoTo(1)
+	if err := g.DoSAN("c5"); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Moves()) != 2 || g.Moves()[1].String() != "c7c5" {
+		t.Errorf("Moves() = %v, want [e2e4 c7c5]", g.Moves())
+	}
+}
+
+func TestGamePromotion(t *testing.T) {
+	cb, err := ParseFEN("7k/P7/6K1/8/8/8/8/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(cb)
+	g.DoMove([2]int8{0, 1}, [2]int8{0, 0})
+	if g.Result != Unfinished {
+		t.Fatalf("game ended by %v before the pawn was promoted", g.Termination)
+	}
+	if g.DoMove([2]int8{7, 0}, [2]int8{7, 1}) {
+		t.Error("DoMove() moved while a promotion was pending")
+	}
+	if err := g.DoSAN("Kg8"); err == nil || g.Ply() != 1 {
+		t.Errorf("DoSAN(Kg8) = %v while a promotion was pending, leaving %d moves", err, g.Ply())
+	}
+	if !g.PromotePawn(0, 0, WhiteQueen) {
+		t.Fatal("PromotePawn() failed")
+	}
+	if g.Result != WhiteWins || g.Termination != Checkmate {
+		t.Errorf("Result, Termination = %v, %v, want white wins by checkmate", g.Result, g.Termination)
+	}
+	if g.Moves()[0].String() != "a7a8q" || g.Position(1).FEN() != g.FEN() {
+		t.Errorf("promotion wasn't recorded: %v", g.Moves())
+	}
+	g.GoTo(0)
+	if g.Board[1][0] != WhitePawn || g.Board[0][0] != 0 {
+		t.Error("GoTo(0) didn't take back the promotion")
+	}
+}
+
+func TestGameDraws(t *testing.T) {
+	g := NewGame(NewChessboard())
+	for i := 0; i < 2; i++ {
+		for _, san := range []string{"Nf3", "Nf6", "Ng1", "Ng8"} {
+			if g.ClaimDraw() {
+				t.Fatal("ClaimDraw() succeeded before a repetition")
+			}
+			if err := g.DoSAN(san); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !g.ClaimDraw() || g.Result != Draw || g.Termination != ThreefoldRepetition {
+		t.Errorf("ClaimDraw() gave %v, %v, want a draw by threefold repetition", g.Result, g.Termination)
+	}
+
+	g = NewGame(NewChessboard())
+	g.Resign(false)
+	if g.Result != BlackWins || g.Termination != Resignation {
+		t.Errorf("Resign() gave %v, %v", g.Result, g.Termination)
+	}
+}
+
+func TestGameTimeout(t *testing.T) {
+	for _, test := range []struct {
+		fen    string
+		black  bool // who runs out of time
+		result Result
+	}{
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", true, WhiteWins},
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", false, Draw},  // black has only its king
+		{"4k3/4p3/8/8/8/8/3N4/4K3 b - - 0 1", true, Draw}, // a knight can't checkmate alone
+		{"4k3/8/8/8/8/8/2BN4/4K3 b - - 0 1", true, WhiteWins},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := NewGame(cb)
+		g.Timeout(test.black)
+		if g.Result != test.result || g.Termination != Timeout {
+			t.Errorf("%s: Timeout(%v) gave %v, %v, want %v", test.fen, test.black, g.Result, g.Termination, test.result)
+		}
+	}
+}
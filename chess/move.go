@@ -0,0 +1,367 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "math/bits"
+
+// Move is a single move, along with everything needed to take it back with Unmake.
+type Move struct {
+	From, To  [2]int8 // for EnPassant To is the pawn being taken, for castling To is where the king lands
+	Type      MoveType
+	Promotion Piece    // piece a pawn reaching the last rank becomes, 0 if none
+	Dropped   Piece    // for Drop, piece placed from the pocket
+	Captured  Piece    // piece taken by the move, 0 if none
+	Exploded  [9]Piece // for Atomic, the pieces around the landing square removed by a capture, then the capturer
+
+	// state before the move
+	PrevCantCastle    [4]bool // WhiteCantCastleLeft, WhiteCantCastleRight, BlackCantCastleLeft, BlackCantCastleRight
+	PrevEnPassant     *[2]int8
+	PrevHalfMoveClock int
+	PrevBlackMove     bool
+	PrevHash          uint64
+	PrevPromoted      uint64
+}
+
+// NewMove returns a move from the current position. Promotion should be 0 unless a pawn is reaching the last rank,
+// or the move is a Drop, in which case it's the piece being dropped.
+func (cb *Chessboard) NewMove(from, to [2]int8, movet MoveType, promotion Piece) Move {
+	m := Move{
+		From:           from,
+		To:             to,
+		Type:           movet,
+		Promotion:      promotion,
+		PrevCantCastle: [4]bool{cb.WhiteCantCastleLeft, cb.WhiteCantCastleRight, cb.BlackCantCastleLeft, cb.BlackCantCastleRight},
+		PrevEnPassant:  cb.CanBeEnPassant,
+
+		PrevHalfMoveClock: cb.HalfMoveClock,
+		PrevBlackMove:     cb.BlackMove,
+		PrevHash:          cb.hash,
+		PrevPromoted:      cb.promoted,
+	}
+	switch movet {
+	case RegularMove, EnPassant:
+		m.Captured = cb.Board[to[1]][to[0]]
+	case CastleLeft, CastleRight:
+		king, _ := castleSquares(movet == CastleLeft)
+		m.To = [2]int8{king, from[1]}
+	case Drop:
+		m.Promotion, m.Dropped = 0, promotion
+	}
+	if cb.Variant != nil {
+		m = cb.Variant.Record(cb, m)
+	}
+	return m
+}
+
+// moving returns the piece a move is made with.
+func (cb *Chessboard) moving(m Move) Piece {
+	if m.Type == Drop {
+		return m.Dropped
+	}
+	return cb.Board[m.From[1]][m.From[0]]
+}
+
+// loseCastling removes the castling rights a rook on x, y provides (if any).
+func (cb *Chessboard) loseCastling(x, y int8) {
+	switch {
+	case y == 0 && x == cb.CastleFile(true, true):
+		cb.BlackCantCastleLeft = true
+	case y == 0 && x == cb.CastleFile(true, false):
+		cb.BlackCantCastleRight = true
+	case y == 7 && x == cb.CastleFile(false, true):
+		cb.WhiteCantCastleLeft = true
+	case y == 7 && x == cb.CastleFile(false, false):
+		cb.WhiteCantCastleRight = true
+	}
+}
+
+// Make performs a move without checking it's legal, and returns true if the opposing player is now in check.
+func (cb *Chessboard) Make(m Move) bool {
+	from, to := m.From, m.To
+	piece := cb.moving(m)
+	black := IsBlack(piece)
+	cb.hash ^= cb.stateKey()
+	cb.CanBeEnPassant = nil
+	if piece == BlackPawn || piece == WhitePawn || m.Captured != 0 {
+		cb.HalfMoveClock = 0
+	} else {
+		cb.HalfMoveClock++
+	}
+	if black {
+		cb.FullMoveNumber++
+	}
+
+	switch m.Type {
+	case RegularMove:
+		if (piece == BlackPawn && from[1] == 1 && to[1] == 3) || (piece == WhitePawn && from[1] == 6 && to[1] == 4) {
+			cb.CanBeEnPassant = &enPassantSquares[to[1]][to[0]]
+		}
+		switch piece {
+		case BlackKing:
+			cb.BlackCantCastleLeft, cb.BlackCantCastleRight = true, true
+		case WhiteKing:
+			cb.WhiteCantCastleLeft, cb.WhiteCantCastleRight = true, true
+		case BlackRook, WhiteRook:
+			cb.loseCastling(from[0], from[1])
+		}
+		if m.Captured == BlackRook || m.Captured == WhiteRook {
+			cb.loseCastling(to[0], to[1])
+		}
+		if m.Promotion != 0 {
+			piece = m.Promotion
+		}
+		cb.set(to[0], to[1], piece)
+		cb.set(from[0], from[1], 0)
+	case EnPassant:
+		land := cb.landing(from, to, EnPassant)
+		cb.set(to[0], to[1], 0)
+		cb.set(land[0], land[1], piece)
+		cb.set(from[0], from[1], 0)
+	case CastleLeft, CastleRight:
+		_, rookTo := castleSquares(m.Type == CastleLeft)
+		rookFrom := cb.CastleFile(black, m.Type == CastleLeft)
+		y := from[1]
+		rook := cb.Board[y][rookFrom]
+		cb.set(from[0], y, 0)
+		cb.set(rookFrom, y, 0)
+		cb.set(to[0], y, piece)
+		cb.set(rookTo, y, rook)
+		if black {
+			cb.BlackCantCastleLeft, cb.BlackCantCastleRight = true, true
+		} else {
+			cb.WhiteCantCastleLeft, cb.WhiteCantCastleRight = true, true
+		}
+	case Drop:
+		cb.set(to[0], to[1], piece)
+		c, i := pocketSlot(piece)
+		cb.pockets[c][i]--
+	}
+
+	cb.BlackMove = !black
+	if cb.Variant != nil {
+		cb.Variant.AfterMove(cb, m)
+	}
+	cb.hash ^= cb.stateKey()
+	cb.history = append(cb.history, cb.hash)
+	return cb.IsCheck(!black)
+}
+
+// Unmake takes back a move performed by Make, which must have been the last move made.
+func (cb *Chessboard) Unmake(m Move) {
+	from, to := m.From, m.To
+	if cb.Variant != nil {
+		cb.Variant.UndoMove(cb, m)
+	}
+	cb.WhiteCantCastleLeft, cb.WhiteCantCastleRight = m.PrevCantCastle[0], m.PrevCantCastle[1]
+	cb.BlackCantCastleLeft, cb.BlackCantCastleRight = m.PrevCantCastle[2], m.PrevCantCastle[3]
+	cb.CanBeEnPassant = m.PrevEnPassant
+	cb.HalfMoveClock = m.PrevHalfMoveClock
+	cb.BlackMove = m.PrevBlackMove
+	if len(cb.history) > 0 {
+		cb.history = cb.history[:len(cb.history)-1]
+	}
+
+	switch m.Type {
+	case RegularMove:
+		piece := cb.Board[to[1]][to[0]]
+		if m.Promotion != 0 {
+			if IsBlack(piece) {
+				piece = BlackPawn
+			} else {
+				piece = WhitePawn
+			}
+		}
+		cb.set(from[0], from[1], piece)
+		cb.set(to[0], to[1], m.Captured)
+	case EnPassant:
+		var land [2]int8
+		if IsBlack(m.Captured) {
+			land = [2]int8{to[0], to[1] - 1}
+		} else {
+			land = [2]int8{to[0], to[1] + 1}
+		}
+		cb.set(from[0], from[1], cb.Board[land[1]][land[0]])
+		cb.set(land[0], land[1], 0)
+		cb.set(to[0], to[1], m.Captured)
+	case CastleLeft, CastleRight:
+		_, rookTo := castleSquares(m.Type == CastleLeft)
+		y := from[1]
+		king, rook := cb.Board[y][to[0]], cb.Board[y][rookTo]
+		rookFrom := cb.CastleFile(IsBlack(king), m.Type == CastleLeft)
+		cb.set(to[0], y, 0)
+		cb.set(rookTo, y, 0)
+		cb.set(from[0], y, king)
+		cb.set(rookFrom, y, rook)
+	case Drop:
+		cb.set(to[0], to[1], 0)
+		c, i := pocketSlot(m.Dropped)
+		cb.pockets[c][i]++
+	}
+	if IsBlack(cb.moving(m)) {
+		cb.FullMoveNumber--
+	}
+	cb.hash = m.PrevHash
+}
+
+// promotions are the pieces a pawn can be promoted to, white then black.
+var promotions = [2][4]Piece{
+	{WhiteQueen, WhiteRook, WhiteBishop, WhiteKnight},
+	{BlackQueen, BlackRook, BlackBishop, BlackKnight},
+}
+
+// antichessPromotions are the pieces a pawn can be promoted to in Antichess, where the king is an ordinary piece.
+var antichessPromotions = [2][5]Piece{
+	{WhiteQueen, WhiteRook, WhiteBishop, WhiteKnight, WhiteKing},
+	{BlackQueen, BlackRook, BlackBishop, BlackKnight, BlackKing},
+}
+
+// promotionPieces returns the pieces a pawn of the specified colour can be promoted to.
+func (cb *Chessboard) promotionPieces(black bool) []Piece {
+	if _, ok := cb.Variant.(Antichess); ok {
+		return antichessPromotions[colour(black)][:]
+	}
+	return promotions[colour(black)][:]
+}
+
+// canPromoteTo returns whether a pawn of the specified colour can be promoted to p.
+func (cb *Chessboard) canPromoteTo(p Piece, black bool) bool {
+	for _, promotion := range cb.promotionPieces(black) {
+		if p == promotion {
+			return true
+		}
+	}
+	return false
+}
+
+// LegalMoves returns every legal move the specified colour can make. A pawn reaching the last rank has a separate
+// move for each piece it can be promoted to.
+func (cb *Chessboard) LegalMoves(black bool) []Move {
+	return cb.AppendLegalMoves(nil, black)
+}
+
+// AppendLegalMoves appends every legal move the specified colour can make to moves, and returns the extended slice.
+// There are none once a variant's game is won.
+// Passing a slice with enough room (256 moves is always enough) avoids allocating.
+func (cb *Chessboard) AppendLegalMoves(moves []Move, black bool) []Move {
+	if cb.VariantResult() != Unfinished {
+		return moves
+	}
+	return cb.appendLegalMoves(moves, black)
+}
+
+// appendLegalMoves is AppendLegalMoves, without checking whether the game is already won.
+func (cb *Chessboard) appendLegalMoves(moves []Move, black bool) []Move {
+	start := len(moves)
+	for pieces := cb.colours[colour(black)]; pieces != 0; pieces &= pieces - 1 {
+		sq := bits.TrailingZeros64(pieces)
+		moves = cb.appendMoves(moves, int8(sq%8), int8(sq/8))
+	}
+	moves = cb.appendDrops(moves, black)
+	if cb.Variant != nil {
+		moves = append(moves[:start], cb.Variant.Restrict(cb, moves[start:])...)
+	}
+	return moves
+}
+
+// appendMoves appends the legal moves of the piece at x, y to moves.
+func (cb *Chessboard) appendMoves(moves []Move, x, y int8) []Move {
+	p := cb.Board[y][x]
+	if !isPiece(p) {
+		return moves
+	}
+	black, i, from := IsBlack(p), pieceIndex(p), bit(x, y)
+	occupied, enemies := cb.occupied(), cb.colours[1-colour(black)]
+	pos := [2]int8{x, y}
+
+	var targets uint64
+	if i%6 == pawnKind {
+		if black {
+			targets = from << 8 &^ occupied
+			if y == 1 { // double-move
+				targets |= targets << 8 &^ occupied
+			}
+		} else {
+			targets = from >> 8 &^ occupied
+			if y == 6 {
+				targets |= targets >> 8 &^ occupied
+			}
+		}
+		targets |= pawnAttacks[colour(black)][square(x, y)] & enemies
+	} else {
+		targets = attacks(i, square(x, y), occupied) &^ cb.colours[colour(black)]
+	}
+	for ; targets != 0; targets &= targets - 1 {
+		to := targets & -targets
+		if cb.Variant == nil && !cb.kingSafe(from, to, to&enemies, black) {
+			continue
+		}
+		sq := bits.TrailingZeros64(to)
+		dest := [2]int8{int8(sq % 8), int8(sq / 8)}
+		if i%6 == pawnKind && (dest[1] == 0 || dest[1] == 7) {
+			for _, promotion := range cb.promotionPieces(black) {
+				moves = cb.appendLegal(moves, cb.NewMove(pos, dest, RegularMove, promotion))
+			}
+			continue
+		}
+		moves = cb.appendLegal(moves, cb.NewMove(pos, dest, RegularMove, 0))
+	}
+
+	switch i % 6 {
+	case pawnKind:
+		if cb.enPassantTakers(black)&from != 0 {
+			ep := *cb.CanBeEnPassant
+			land := bit(ep[0], ep[1]-1)
+			if black {
+				land = bit(ep[0], ep[1]+1)
+			}
+			if cb.Variant != nil || cb.kingSafe(from, land, bit(ep[0], ep[1]), black) {
+				moves = cb.appendLegal(moves, cb.NewMove(pos, ep, EnPassant, 0))
+			}
+		}
+	case kingKind:
+		left, right := cb.canCastle(x, y)
+		if left {
+			moves = cb.appendLegal(moves, cb.NewMove(pos, pos, CastleLeft, 0))
+		}
+		if right {
+			moves = cb.appendLegal(moves, cb.NewMove(pos, pos, CastleRight, 0))
+		}
+	}
+	return moves
+}
+
+// appendLegal appends m to moves, unless the board's variant doesn't allow it. Without a variant, moves are checked
+// before they're created.
+func (cb *Chessboard) appendLegal(moves []Move, m Move) []Move {
+	if cb.Variant != nil {
+		black := IsBlack(cb.moving(m))
+		cb.Make(m)
+		legal := cb.Variant.Legal(cb, black)
+		cb.Unmake(m)
+		if !legal {
+			return moves
+		}
+	}
+	return append(moves, m)
+}
+
+// String returns the move in coordinate notation (ie: "e2e4", "e7e8q", "e1g1" or "N@f3"), giving the square the
+// piece lands on for en passant.
+func (m Move) String() string {
+	if m.Type == Drop {
+		return string(Letter(m.Dropped)&^0x20) + "@" + squareName(m.To[0], m.To[1])
+	}
+	to := m.To
+	if m.Type == EnPassant {
+		if to[1] == 3 {
+			to[1] = 2
+		} else {
+			to[1] = 5
+		}
+	}
+	s := squareName(m.From[0], m.From[1]) + squareName(to[0], to[1])
+	if m.Promotion != 0 {
+		s += string(Letter(m.Promotion) | 0x20)
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLegalMoves(t *testing.T) {
+	for _, test := range []struct {
+		name, fen, from string
+		want            string // every legal move from the square, sorted
+	}{
+		{"promotion", "4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7", "a7a8b a7a8n a7a8q a7a8r"},
+		{"capturing promotion", "1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7",
+			"a7a8b a7a8n a7a8q a7a8r a7b8b a7b8n a7b8q a7b8r"},
+		{"pinned knight", "4k3/4r3/8/8/8/8/4N3/4K3 w - - 0 1", "e2", ""},
+		{"pinned bishop", "4k3/4r3/8/8/8/8/4B3/4K3 w - - 0 1", "e2", ""},
+		{"bishop pinned on its diagonal", "4k3/8/8/1b6/8/8/4B3/5K2 w - - 0 1", "e2", "e2b5 e2c4 e2d3"},
+		{"pinned rook", "4k3/4r3/8/8/8/8/4R3/4K3 w - - 0 1", "e2", "e2e3 e2e4 e2e5 e2e6 e2e7"},
+		{"moves into check", "4k3/3r4/8/8/8/8/8/4K3 w - - 0 1", "e1", "e1e2 e1f1 e1f2"},
+		{"moves next to the king", "8/8/8/8/8/4k3/8/4K3 w - - 0 1", "e1", "e1d1 e1f1"},
+		{"en passant", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", "e5", "e5d6 e5e6"},
+		{"black en passant", "4k3/8/8/8/3Pp3/8/8/4K3 b - d3 0 1", "e4", "e4d3 e4e3"},
+		// taking en passant would leave the king in check along the rank
+		{"pinned en passant", "4k3/8/8/K2pP2r/8/8/8/8 w - d6 0 2", "e5", "e5e6"},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range cb.LegalMoves(cb.BlackMove) {
+			if s := m.String(); strings.HasPrefix(s, test.from) {
+				got = append(got, s)
+			}
+		}
+		sort.Strings(got)
+		if want := strings.Fields(test.want); !reflect.DeepEqual(got, want) && len(got)+len(want) > 0 {
+			t.Errorf("%s: LegalMoves() from %s = %v, want %v", test.name, test.from, got, want)
+		}
+	}
+}
+
+func TestAppendLegalMoves(t *testing.T) {
+	cb, err := ParseFEN("4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cb.LegalMoves(cb.BlackMove)
+	prefix := []Move{cb.NewMove([2]int8{4, 7}, [2]int8{3, 7}, RegularMove, 0)}
+	moves := cb.AppendLegalMoves(prefix, cb.BlackMove)
+	if len(moves) != len(want)+1 || moves[0].String() != "e1d1" {
+		t.Fatalf("AppendLegalMoves() = %v, want e1d1 followed by %v", moves, want)
+	}
+	for i, m := range moves[1:] {
+		if m.String() != want[i].String() {
+			t.Errorf("AppendLegalMoves()[%d] = %s, want %s", i+1, m, want[i])
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+// Perft counts the positions reachable in exactly depth moves. It's used to check move generation against known
+// counts.
+func (cb *Chessboard) Perft(depth int) uint64 {
+	if depth <= 0 {
+		return 1
+	}
+	var buf [maxMoves]Move
+	moves := cb.AppendLegalMoves(buf[:0], cb.BlackMove)
+	if depth == 1 {
+		return uint64(len(moves))
+	}
+	var nodes uint64
+	for _, m := range moves {
+		cb.Make(m)
+		nodes += cb.Perft(depth - 1)
+		cb.Unmake(m)
+	}
+	return nodes
+}
+
+// Divide returns the Perft count below each legal move, keyed by the move in coordinate notation (ie: "e2e4").
+func (cb *Chessboard) Divide(depth int) map[string]uint64 {
+	out := make(map[string]uint64)
+	for _, m := range cb.LegalMoves(cb.BlackMove) {
+		cb.Make(m)
+		out[m.String()] = cb.Perft(depth - 1)
+		cb.Unmake(m)
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+// perftPositions are the standard perft reference positions, with their known node counts by depth.
+var perftPositions = []struct {
+	name  string
+	fen   string
+	nodes []uint64
+}{
+	{"start", StartingFEN, []uint64{20, 400, 8902, 197281, 4865609}},
+	{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", []uint64{48, 2039, 97862, 4085603}},
+	{"position3", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []uint64{14, 191, 2812, 43238, 674624}},
+	{"position4", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []uint64{6, 264, 9467, 422333}},
+	{"position5", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []uint64{44, 1486, 62379, 2103487}},
+	{"position6", "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", []uint64{46, 2079, 89890}},
+}
+
+func TestPerft(t *testing.T) {
+	for _, pos := range perftPositions {
+		t.Run(pos.name, func(t *testing.T) {
+			cb, err := ParseFEN(pos.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := cb.FEN()
+			for i, want := range pos.nodes {
+				if testing.Short() && want > 100000 {
+					break
+				}
+				if got := cb.Perft(i + 1); got != want {
+					t.Errorf("depth %d: got %d nodes, want %d", i+1, got, want)
+				}
+			}
+			if fen := cb.FEN(); fen != before {
+				t.Errorf("position changed after perft: %s", fen)
+			}
+		})
+	}
+}
+
+func TestDivide(t *testing.T) {
+	cb := NewChessboard()
+	divide := cb.Divide(2)
+	if len(divide) != 20 {
+		t.Fatalf("got %d moves, want 20", len(divide))
+	}
+	for move, nodes := range divide {
+		if nodes != 20 {
+			t.Errorf("%s: got %d nodes, want 20", move, nodes)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+// propertyPositions are walked by the property tests, along with the perft positions.
+var propertyPositions = []struct {
+	fen     string
+	variant Variant
+}{
+	{StartingFEN, Atomic{}},
+	{StartingFEN, Antichess{}},
+	{StartingFEN, ThreeCheck{}},
+	{"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R[Pp] w KQkq - 0 1", nil},
+	{"4k3/1P6/8/8/8/8/6p1/4K3[QRBNPqrbnp] w - - 0 1", nil},
+	{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", nil},
+}
+
+// checkMove checks the invariants of making a legal move on cb: the mover's king isn't left attacked, the move's SAN
+// parses back to it, and Unmake restores the position exactly.
+func checkMove(t *testing.T, cb *Chessboard, m Move) {
+	t.Helper()
+	fen, pieces, colours, hash := cb.FEN(), cb.pieces, cb.colours, cb.Hash()
+	promoted, checks, history := cb.promoted, cb.Checks, len(cb.history)
+	black := IsBlack(cb.moving(m))
+
+	piece := m.Promotion
+	if m.Type == Drop {
+		piece = m.Dropped
+	}
+	san, err := cb.SAN(m.From, m.To, m.Type, piece)
+	if err != nil {
+		t.Fatalf("%s: SAN(%s): %v", fen, m, err)
+	}
+	from, to, movet, promotion, err := cb.ParseSAN(san)
+	if err != nil || from != m.From || to != m.To || movet != m.Type || promotion != piece {
+		t.Fatalf("%s: %s parsed back from %q as %v %v %v %v, %v", fen, m, san, from, to, movet, promotion, err)
+	}
+
+	cb.Make(m)
+	if cb.Variant == nil && cb.kingAttacked(black) {
+		t.Fatalf("%s: %s leaves the king in check", fen, m)
+	}
+	cb.Unmake(m)
+	if cb.FEN() != fen || cb.pieces != pieces || cb.colours != colours || cb.Hash() != hash ||
+		cb.promoted != promoted || cb.Checks != checks || len(cb.history) != history {
+		t.Fatalf("%s: position is %s after making and taking back %s", fen, cb.FEN(), m)
+	}
+}
+
+// walk checks every legal move depth moves deep.
+func walk(t *testing.T, cb *Chessboard, depth int) {
+	if depth == 0 {
+		return
+	}
+	for _, m := range cb.LegalMoves(cb.BlackMove) {
+		checkMove(t, cb, m)
+		cb.Make(m)
+		walk(t, cb, depth-1)
+		cb.Unmake(m)
+	}
+}
+
+func TestMoveProperties(t *testing.T) {
+	depth := 2
+	if testing.Short() {
+		depth = 1
+	}
+	for _, pos := range perftPositions {
+		cb, err := ParseFEN(pos.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		walk(t, cb, depth)
+	}
+	for _, pos := range propertyPositions {
+		cb, err := ParseFEN(pos.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos.variant != nil {
+			cb.Variant = pos.variant
+		}
+		walk(t, cb, depth)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderOptions control how a board is drawn by Render and SVG.
+type RenderOptions struct {
+	ASCII       bool     // draw pieces with their FEN letters rather than Unicode symbols, ignored by SVG
+	Flip        bool     // draw the board from black's side
+	Coordinates bool     // label the files and ranks
+	Highlight   []Square // squares to mark, ie: the last move
+}
+
+// String returns a Unicode diagram of the board from white's side, with coordinates.
+func (cb *Chessboard) String() string {
+	return cb.Render(RenderOptions{Coordinates: true})
+}
+
+// order returns the rows and columns of the board in the order they're drawn.
+func (opts RenderOptions) order() (order [8]int8) {
+	for i := range order {
+		order[i] = int8(i)
+		if opts.Flip {
+			order[i] = int8(7 - i)
+		}
+	}
+	return
+}
+
+// highlighted returns true if s is one of the highlighted squares.
+func (opts RenderOptions) highlighted(s Square) bool {
+	for _, h := range opts.Highlight {
+		if h == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Render returns a text diagram of the board, one line per rank. Highlighted squares are drawn in brackets (ie:
+// "[N]"). In Crazyhouse, the pockets follow the board as in FEN (ie: "[QNPp]").
+func (cb *Chessboard) Render(opts RenderOptions) string {
+	var sb strings.Builder
+	order := opts.order()
+	for _, y := range order {
+		var line string
+		if opts.Coordinates {
+			line = fmt.Sprintf("%d ", 8-y)
+		}
+		for _, x := range order {
+			p, s := cb.Board[y][x], SquareAt(x, y)
+			var symbol string
+			switch {
+			case !isPiece(p) && opts.ASCII:
+				symbol = "."
+			case !isPiece(p):
+				symbol = "·"
+			case opts.ASCII:
+				symbol = string(Letter(p))
+			default:
+				symbol = string(p)
+			}
+			if opts.highlighted(s) {
+				line += "[" + symbol + "]"
+			} else {
+				line += " " + symbol + " "
+			}
+		}
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	if opts.Coordinates {
+		line := "  "
+		for _, x := range order {
+			line += " " + string('a'+byte(x)) + " "
+		}
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	if _, ok := cb.Variant.(Crazyhouse); ok {
+		sb.WriteString("[" + cb.pocketLetters() + "]\n")
+	}
+	return sb.String()
+}
+
+// svgSquare is the size of a square in an SVG diagram.
+const svgSquare = 45
+
+// SVG returns an SVG diagram of the board, coloured like the client's. Pieces are drawn as Unicode symbols.
+func (cb *Chessboard) SVG(opts RenderOptions) string {
+	var sb strings.Builder
+	margin := 0
+	if opts.Coordinates {
+		margin = svgSquare / 2
+	}
+	size := 8*svgSquare + margin
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, size, size, size, size)
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="white"/>`, size, size)
+	sb.WriteString("\n")
+
+	order := opts.order()
+	for row, y := range order {
+		for col, x := range order {
+			left, top := margin+col*svgSquare, row*svgSquare
+			fill := "white"
+			switch {
+			case opts.highlighted(SquareAt(x, y)):
+				fill = "red"
+			case (x+y)%2 == 1:
+				fill = "brown"
+			}
+			fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, left, top, svgSquare, svgSquare, fill)
+			if p := cb.Board[y][x]; isPiece(p) {
+				fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central">%c</text>`,
+					left+svgSquare/2, top+svgSquare/2, svgSquare*4/5, p)
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if opts.Coordinates {
+		for i, v := range order {
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central">%d</text>`,
+				margin/2, i*svgSquare+svgSquare/2, margin*2/3, 8-v)
+			fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central">%c</text>`,
+				margin+i*svgSquare+svgSquare/2, 8*svgSquare+margin/2, margin*2/3, 'a'+v)
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
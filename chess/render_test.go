@@ -0,0 +1,63 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	cb := NewChessboard()
+	if err := cb.DoSAN("e4"); err != nil {
+		t.Fatal(err)
+	}
+	e2, _ := ParseSquare("e2")
+	e4, _ := ParseSquare("e4")
+	want := `8  r  n  b  q  k  b  n  r
+7  p  p  p  p  p  p  p  p
+6  .  .  .  .  .  .  .  .
+5  .  .  .  .  .  .  .  .
+4  .  .  .  . [P] .  .  .
+3  .  .  .  .  .  .  .  .
+2  P  P  P  P [.] P  P  P
+1  R  N  B  Q  K  B  N  R
+   a  b  c  d  e  f  g  h
+`
+	if got := cb.Render(RenderOptions{ASCII: true, Coordinates: true, Highlight: []Square{e2, e4}}); got != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", got, want)
+	}
+
+	want = ` ♖  ♘  ♗  ♔  ♕  ♗  ♘  ♖
+ ♙  ♙  ♙  ·  ♙  ♙  ♙  ♙
+`
+	if got := cb.Render(RenderOptions{Flip: true}); !strings.HasPrefix(got, want) || strings.Count(got, "\n") != 8 {
+		t.Errorf("flipped Render() =\n%s\nwant it to start with\n%s", got, want)
+	}
+	if !strings.HasPrefix(cb.String(), "8  ♜  ♞") {
+		t.Errorf("String() =\n%s", cb)
+	}
+
+	cb, err := ParseFEN("4k3/8/8/8/8/8/8/4K3[Nn] w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cb.Render(RenderOptions{ASCII: true}); !strings.HasSuffix(got, "\n[Nn]\n") {
+		t.Errorf("Crazyhouse Render() =\n%s\nshould end with its pockets", got)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	cb := NewChessboard()
+	e2, _ := ParseSquare("e2")
+	svg := cb.SVG(RenderOptions{Coordinates: true, Highlight: []Square{e2}})
+	if !strings.HasPrefix(svg, "<svg ") || !strings.HasSuffix(svg, "</svg>\n") {
+		t.Errorf("SVG() isn't an svg element:\n%s", svg)
+	}
+	if n := strings.Count(svg, `fill="red"`); n != 1 {
+		t.Errorf("SVG() has %d highlighted squares, want 1", n)
+	}
+	if n := strings.Count(svg, "<text"); n != 32+16 {
+		t.Errorf("SVG() has %d pieces and labels, want 48", n)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// pieceOf returns the piece of the given colour represented by an uppercase SAN letter (ie: 'N'), or a pawn if
+// l is 0.
+func pieceOf(l byte, black bool) Piece {
+	if l == 0 {
+		l = 'P'
+	}
+	if black {
+		l += 'a' - 'A'
+	}
+	return PieceFromLetter(l)
+}
+
+// sanLetter returns the uppercase SAN letter of a piece, or 0 for a pawn.
+func sanLetter(p Piece) byte {
+	l := Letter(p)
+	if l >= 'a' {
+		l -= 'a' - 'A'
+	}
+	if l == 'P' {
+		return 0
+	}
+	return l
+}
+
+// landing returns the square a piece ends up on, which differs from to for en passant.
+func (cb *Chessboard) landing(from, to [2]int8, movet MoveType) [2]int8 {
+	if movet == EnPassant {
+		if IsBlack(cb.Board[from[1]][from[0]]) {
+			to[1]++
+		} else {
+			to[1]--
+		}
+	}
+	return to
+}
+
+// SAN returns a move in Standard Algebraic Notation (ie: "Nbxd7+", or "N@f3" for a drop). Promotion is the piece a
+// pawn reaching the last rank is promoted to, or the piece being dropped. Returns an error if the move isn't legal.
+func (cb *Chessboard) SAN(from, to [2]int8, movet MoveType, promotion Piece) (string, error) {
+	if movet == Drop {
+		if !cb.CanDrop(promotion, to) {
+			return "", errors.New("Illegal move")
+		}
+		m := cb.NewMove(to, to, Drop, promotion)
+		return cb.checkSuffix(m.String(), m), nil
+	}
+	if !onBoard(from) || !onBoard(to) {
+		return "", errors.New("Not on the board")
+	}
+	piece := cb.Board[from[1]][from[0]]
+	if piece == 0 {
+		return "", errors.New("No piece to move")
+	}
+	if !cb.IsLegal(from, to, movet) {
+		return "", errors.New("Illegal move")
+	}
+	black := IsBlack(piece)
+
+	var san string
+	switch movet {
+	case CastleLeft:
+		san = "O-O-O"
+	case CastleRight:
+		san = "O-O"
+	default:
+		dest := cb.landing(from, to, movet)
+		capture := movet == EnPassant || cb.Board[to[1]][to[0]] != 0
+		if letter := sanLetter(piece); letter != 0 {
+			san = string(letter)
+			// disambiguate between identical pieces that can reach the same square
+			var others, sameFile, sameRank bool
+			for y := range cb.Board {
+				for x, p := range cb.Board[y] {
+					if p != piece || (int8(x) == from[0] && int8(y) == from[1]) {
+						continue
+					}
+					moves, _, _, _ := cb.PossibleMoves(SquareAt(int8(x), int8(y)))
+					for _, move := range moves {
+						if move == SquareAt(to[0], to[1]) {
+							others = true
+							sameFile = sameFile || int8(x) == from[0]
+							sameRank = sameRank || int8(y) == from[1]
+							break
+						}
+					}
+				}
+			}
+			if others {
+				name := squareName(from[0], from[1])
+				if !sameFile {
+					san += name[:1]
+				} else if !sameRank {
+					san += name[1:]
+				} else {
+					san += name
+				}
+			}
+		} else if capture {
+			san = squareName(from[0], from[1])[:1]
+		}
+		if capture {
+			san += "x"
+		}
+		san += squareName(dest[0], dest[1])
+		if promotion != 0 {
+			if sanLetter(piece) != 0 || (dest[1] != 0 && dest[1] != 7) {
+				return "", errors.New("Only pawns reaching the last rank can promote")
+			}
+			if !cb.canPromoteTo(promotion, black) {
+				return "", errors.New("Invalid promotion piece")
+			}
+			san += "=" + string(sanLetter(promotion))
+		}
+	}
+
+	return cb.checkSuffix(san, cb.NewMove(from, to, movet, promotion)), nil
+}
+
+// checkSuffix returns san with "+" added if m gives check, or "#" if it gives checkmate.
+func (cb *Chessboard) checkSuffix(san string, m Move) string {
+	black := IsBlack(cb.moving(m))
+	if cb.Make(m) {
+		if len(cb.LegalMoves(!black)) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+	cb.Unmake(m)
+	return san
+}
+
+// ParseSAN resolves a move in Standard Algebraic Notation made by the side to move into a legal move. Check,
+// mate and annotation suffixes (ie: "+", "#", "!?") are ignored, but "x" must mark a capture. For a drop (ie: "N@f3"),
+// promotion is the piece being dropped. Returns an error if the move is malformed, illegal or ambiguous.
+func (cb *Chessboard) ParseSAN(san string) (from, to [2]int8, movet MoveType, promotion Piece, err error) {
+	black := cb.BlackMove
+	s := strings.TrimRight(san, "+#!?")
+
+	// castling
+	switch strings.ReplaceAll(s, "0", "O") {
+	case "O-O", "O-O-O":
+		king := WhiteKing
+		if black {
+			king = BlackKing
+		}
+		movet = CastleRight
+		if len(s) == 5 {
+			movet = CastleLeft
+		}
+		for y := range cb.Board {
+			for x, p := range cb.Board[y] {
+				if p == king && cb.IsLegal([2]int8{int8(x), int8(y)}, to, movet) {
+					from = [2]int8{int8(x), int8(y)}
+					kingTo, _ := castleSquares(movet == CastleLeft)
+					to = [2]int8{kingTo, from[1]}
+					return
+				}
+			}
+		}
+		err = fmt.Errorf("Illegal move %q", san)
+		return
+	}
+
+	// drop
+	if i := strings.IndexByte(s, '@'); i >= 0 {
+		x, y, ok := parseSquare(s[i+1:])
+		if i > 1 || !ok || (i == 1 && strings.IndexByte("QRBNP", s[0]) < 0) {
+			err = fmt.Errorf("Malformed drop %q", san)
+			return
+		}
+		letter := byte('P')
+		if i == 1 {
+			letter = s[0]
+		}
+		from, to, movet, promotion = [2]int8{x, y}, [2]int8{x, y}, Drop, pieceOf(letter, black)
+		if !cb.CanDrop(promotion, to) {
+			err = fmt.Errorf("Illegal move %q", san)
+		}
+		return
+	}
+
+	// promotion
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		if i != len(s)-2 {
+			err = fmt.Errorf("Malformed promotion in %q", san)
+			return
+		}
+		promotion = pieceOf(s[i+1], black)
+		s = s[:i]
+	} else if len(s) > 2 && strings.IndexByte("QRBNK", s[len(s)-1]) >= 0 && s[len(s)-2] >= '1' && s[len(s)-2] <= '8' {
+		promotion = pieceOf(s[len(s)-1], black)
+		s = s[:len(s)-1]
+	}
+	if promotion != 0 && !cb.canPromoteTo(promotion, black) {
+		err = fmt.Errorf("Invalid promotion piece in %q", san)
+		return
+	}
+
+	// destination
+	if len(s) < 2 {
+		err = fmt.Errorf("Malformed move %q", san)
+		return
+	}
+	dx, dy, ok := parseSquare(s[len(s)-2:])
+	if !ok {
+		err = fmt.Errorf("Malformed destination square in %q", san)
+		return
+	}
+	s = s[:len(s)-2]
+
+	// piece, disambiguation and capture
+	var letter byte
+	if len(s) > 0 && strings.IndexByte("KQRBN", s[0]) >= 0 {
+		letter = s[0]
+		s = s[1:]
+	}
+	capture := strings.HasSuffix(s, "x")
+	s = strings.TrimSuffix(s, "x")
+	fileHint, rankHint := int8(-1), int8(-1)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= 'a' && c <= 'h' && fileHint < 0 && rankHint < 0:
+			fileHint = int8(c - 'a')
+		case c >= '1' && c <= '8' && rankHint < 0:
+			rankHint = int8('8' - c)
+		default:
+			err = fmt.Errorf("Malformed move %q", san)
+			return
+		}
+	}
+	if letter == 0 && capture && fileHint < 0 {
+		err = fmt.Errorf("Pawn capture %q is missing its file", san)
+		return
+	}
+
+	if letter == 0 && (dy == 0 || dy == 7) && promotion == 0 {
+		err = fmt.Errorf("Move %q is missing a promotion piece", san)
+		return
+	}
+
+	piece := pieceOf(letter, black)
+	dest := [2]int8{dx, dy}
+	found, quiet := 0, false
+	for _, m := range cb.LegalMoves(black) {
+		if m.Type == CastleLeft || m.Type == CastleRight || cb.Board[m.From[1]][m.From[0]] != piece {
+			continue
+		}
+		if (fileHint >= 0 && m.From[0] != fileHint) || (rankHint >= 0 && m.From[1] != rankHint) {
+			continue
+		}
+		if cb.landing(m.From, m.To, m.Type) != dest || m.Promotion != promotion {
+			continue
+		}
+		if capture && m.Type != EnPassant && cb.Board[m.To[1]][m.To[0]] == 0 {
+			quiet = true // marked as a capture, but there's nothing to take
+			continue
+		}
+		from, to, movet = m.From, m.To, m.Type
+		found++
+	}
+	switch {
+	case found == 0 && quiet:
+		err = fmt.Errorf("Move %q captures nothing", san)
+	case found == 0:
+		err = fmt.Errorf("Illegal move %q", san)
+	case found > 1:
+		err = fmt.Errorf("Ambiguous move %q", san)
+	}
+	return
+}
+
+// DoSAN performs a move in Standard Algebraic Notation made by the side to move, including any promotion.
+func (cb *Chessboard) DoSAN(san string) error {
+	from, to, movet, promotion, err := cb.ParseSAN(san)
+	if err != nil {
+		return err
+	}
+	cb.Make(cb.NewMove(from, to, movet, promotion))
+	return nil
+}
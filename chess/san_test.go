@@ -0,0 +1,147 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+// sanPositions are positions used to test SAN, by name.
+var sanPositions = map[string]string{
+	"start":     StartingFEN,
+	"files":     "4k3/8/8/8/8/8/8/R4RK1 w - - 0 1",
+	"ranks":     "4k3/8/8/R7/8/8/8/R3K3 w - - 0 1",
+	"squares":   "7k/8/8/8/Q1Q5/8/Q7/4K3 w - - 0 1",
+	"promotion": "1n6/P3k3/8/8/8/8/8/4K3 w - - 0 1",
+	"castling":  "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+	"drops":     "4k3/8/8/8/8/8/8/4K3[Np] w - - 0 1",
+	"passant":   "rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3",
+	"mate":      "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq g3 0 2",
+	"check":     "4k3/8/8/8/8/8/8/R3K3 w - - 0 1",
+}
+
+func TestParseSAN(t *testing.T) {
+	for _, test := range []struct {
+		pos, san, want string // want is the move in coordinate notation
+	}{
+		{"start", "e4", "e2e4"},
+		{"start", "Nf3", "g1f3"},
+		{"start", "e4+!?", "e2e4"},
+		{"files", "Rad1", "a1d1"},
+		{"files", "Rfd1", "f1d1"},
+		{"files", "Ra2", "a1a2"},
+		{"ranks", "R5a3", "a5a3"},
+		{"ranks", "R1a3", "a1a3"},
+		{"squares", "Qcb3", "c4b3"},
+		{"squares", "Q2b3", "a2b3"},
+		{"squares", "Qa4b3", "a4b3"},
+		{"promotion", "a8=Q", "a7a8q"},
+		{"promotion", "a8N", "a7a8n"},
+		{"promotion", "axb8=R", "a7b8r"},
+		{"castling", "O-O", "e1g1"},
+		{"castling", "0-0-0", "e1c1"},
+		{"drops", "N@f3", "N@f3"},
+		{"passant", "exd6", "e5d6"},
+		{"mate", "Qh4#", "d8h4"},
+		{"check", "Ra8+", "a1a8"},
+	} {
+		cb, err := ParseFEN(sanPositions[test.pos])
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, to, movet, promotion, err := cb.ParseSAN(test.san)
+		if err != nil {
+			t.Errorf("%s: ParseSAN(%q): %v", test.pos, test.san, err)
+			continue
+		}
+		m := cb.NewMove(from, to, movet, promotion)
+		if m.String() != test.want {
+			t.Errorf("%s: ParseSAN(%q) = %s, want %s", test.pos, test.san, m, test.want)
+		}
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	for _, test := range []struct {
+		pos, san string
+	}{
+		// ambiguous
+		{"files", "Rd1"},
+		{"ranks", "Ra3"},
+		{"squares", "Qb3"},
+		{"squares", "Qab3"},
+		{"squares", "Q4b3"},
+		// illegal
+		{"start", "e5"},
+		{"start", "Ke2"},
+		{"start", "O-O"},
+		{"start", "Nxf3"},
+		{"start", "Nc3xd5"},
+		{"files", "Raxd1"},
+		{"passant", "exf6"},
+		{"drops", "P@e4"},
+		{"drops", "Q@d4"},
+		{"drops", "N@e1"},
+		{"check", "Rb8+"},
+		// malformed
+		{"start", ""},
+		{"start", "e"},
+		{"start", "e9"},
+		{"start", "Z4"},
+		{"start", "xe4"},
+		{"start", "Nb1b2c3"},
+		{"drops", "N@"},
+		{"drops", "K@d4"},
+		{"drops", "NN@d4"},
+		{"promotion", "a8"},
+		{"promotion", "a8=K"},
+		{"promotion", "a8=Q=Q"},
+		{"start", "e4=Q"},
+	} {
+		cb, err := ParseFEN(sanPositions[test.pos])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if from, to, movet, promotion, err := cb.ParseSAN(test.san); err == nil {
+			t.Errorf("%s: ParseSAN(%q) = %s, want an error", test.pos, test.san, cb.NewMove(from, to, movet, promotion))
+		}
+	}
+}
+
+func TestSAN(t *testing.T) {
+	for _, test := range []struct {
+		pos, move, want string
+	}{
+		{"start", "g1f3", "Nf3"},
+		{"files", "a1d1", "Rad1"},
+		{"ranks", "a5a3", "R5a3"},
+		{"squares", "a4b3", "Qa4b3"},
+		{"squares", "c4b3", "Qcb3"},
+		{"promotion", "a7b8q", "axb8=Q"},
+		{"passant", "e5d6", "exd6"},
+		{"mate", "d8h4", "Qh4#"},
+		{"check", "a1a8", "Ra8+"},
+	} {
+		cb, err := ParseFEN(sanPositions[test.pos])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m Move
+		for _, legal := range cb.LegalMoves(cb.BlackMove) {
+			if legal.String() == test.move {
+				m = legal
+			}
+		}
+		san, err := cb.SAN(m.From, m.To, m.Type, m.Promotion)
+		if err != nil || san != test.want {
+			t.Errorf("%s: SAN(%s) = %q, %v, want %q", test.pos, test.move, san, err, test.want)
+		}
+	}
+
+	cb, _ := ParseFEN(sanPositions["drops"])
+	if san, err := cb.SAN([2]int8{}, [2]int8{5, 5}, Drop, WhiteKnight); err != nil || san != "N@f3" {
+		t.Errorf("SAN(N@f3) = %q, %v", san, err)
+	}
+	cb, _ = ParseFEN(sanPositions["castling"])
+	if san, err := cb.SAN([2]int8{4, 7}, [2]int8{4, 7}, CastleLeft, 0); err != nil || san != "O-O-O" {
+		t.Errorf("SAN(O-O-O) = %q, %v", san, err)
+	}
+}
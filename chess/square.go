@@ -0,0 +1,78 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "fmt"
+
+// Square is a square on the board. Squares are numbered in the same order as Board, from a8 (0) along each rank to
+// h1 (63).
+type Square int8
+
+// NoSquare is returned in place of a square which isn't on the board.
+const NoSquare Square = -1
+
+// SquareAt returns the square at the board coordinates x, y (ie: Board[y][x]), or NoSquare if they're off the board.
+func SquareAt(x, y int8) Square {
+	if x < 0 || x > 7 || y < 0 || y > 7 {
+		return NoSquare
+	}
+	return Square(y*8 + x)
+}
+
+// ParseSquare parses an algebraic square name (ie: "e4").
+func ParseSquare(name string) (Square, error) {
+	x, y, ok := parseSquare(name)
+	if !ok {
+		return NoSquare, fmt.Errorf("%q is not a square", name)
+	}
+	return SquareAt(x, y), nil
+}
+
+// Valid returns true if the square is on the board.
+func (s Square) Valid() bool {
+	return s >= 0 && s < 64
+}
+
+// X returns the square's column in Board, 0 being the a-file.
+func (s Square) X() int8 {
+	return int8(s) % 8
+}
+
+// Y returns the square's row in Board, 0 being the 8th rank (black's back rank).
+func (s Square) Y() int8 {
+	return int8(s) / 8
+}
+
+// Coords returns the square's board coordinates, as taken by the Chessboard's move methods.
+func (s Square) Coords() [2]int8 {
+	return [2]int8{s.X(), s.Y()}
+}
+
+// File returns the square's file, from 'a' to 'h'.
+func (s Square) File() byte {
+	return 'a' + byte(s.X())
+}
+
+// Rank returns the square's rank, from 1 (white's back rank) to 8.
+func (s Square) Rank() int {
+	return 8 - int(s.Y())
+}
+
+// Flip returns the square on the same file and the opposite rank (ie: e2 for e7), as the other colour sees it.
+func (s Square) Flip() Square {
+	return s ^ 56
+}
+
+// Rotate returns the square in the same place on a board turned around (ie: d7 for e2), which is how a board is
+// drawn for black.
+func (s Square) Rotate() Square {
+	return 63 - s
+}
+
+// String returns the square's algebraic name (ie: "e4"), or "-" if it isn't on the board.
+func (s Square) String() string {
+	if !s.Valid() {
+		return "-"
+	}
+	return squareName(s.X(), s.Y())
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+func TestSquare(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		x, y       int8
+		file       byte
+		rank       int
+		flip, turn string
+	}{
+		{"a8", 0, 0, 'a', 8, "a1", "h1"},
+		{"h1", 7, 7, 'h', 1, "h8", "a8"},
+		{"e4", 4, 4, 'e', 4, "e5", "d5"},
+		{"c7", 2, 1, 'c', 7, "c2", "f2"},
+	} {
+		s, err := ParseSquare(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != SquareAt(test.x, test.y) || s.X() != test.x || s.Y() != test.y {
+			t.Errorf("ParseSquare(%q) = %d, want %d, %d", test.name, s, test.x, test.y)
+		}
+		if s.String() != test.name || s.File() != test.file || s.Rank() != test.rank {
+			t.Errorf("%s: String, File, Rank = %s, %c, %d", test.name, s, s.File(), s.Rank())
+		}
+		if s.Flip().String() != test.flip || s.Rotate().String() != test.turn {
+			t.Errorf("%s: Flip, Rotate = %s, %s, want %s, %s", test.name, s.Flip(), s.Rotate(), test.flip, test.turn)
+		}
+	}
+
+	for _, name := range []string{"", "e", "e9", "i1", "E4", "e44"} {
+		if s, err := ParseSquare(name); err == nil || s != NoSquare {
+			t.Errorf("ParseSquare(%q) = %s, %v, want an error", name, s, err)
+		}
+	}
+	if SquareAt(8, 0) != NoSquare || SquareAt(0, -1) != NoSquare || NoSquare.Valid() || NoSquare.String() != "-" {
+		t.Error("Squares off the board should be NoSquare")
+	}
+}
+
+func TestPossibleMovesSquares(t *testing.T) {
+	cb := NewChessboard()
+	e2, _ := ParseSquare("e2")
+	moves, _, _, _ := cb.PossibleMoves(e2)
+	if len(moves) != 2 || moves[0].String()+moves[1].String() != "e4e3" {
+		t.Errorf("PossibleMoves(e2) = %v, want [e4 e3]", moves)
+	}
+	if threat := cb.Threat(false); !threat[SquareAt(5, 5)] || threat[SquareAt(4, 4)] {
+		t.Error("Threat should cover f3 but not e4 for white")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Problem is a kind of PositionError.
+type Problem int
+
+const (
+	InvalidPiece     Problem = iota + 1 // a square holds something which isn't a piece
+	MissingKing                         // a colour has no king
+	MultipleKings                       // a colour has more than one king
+	PawnOnBackRank                      // a pawn is on the first or last rank
+	OpponentInCheck                     // the side which just moved is in check
+	InvalidCastling                     // a castling right has no king or rook to castle with
+	InvalidEnPassant                    // the pawn which can be taken en passant can't have just made a double-move
+	TooManyPieces                       // a colour has more pieces than it could have from the starting position
+)
+
+// PositionError is a reason a position can't be reached in a game.
+type PositionError struct {
+	Problem Problem
+	Black   bool    // colour the problem concerns
+	Square  [2]int8 // square the problem concerns, for InvalidPiece, PawnOnBackRank, InvalidCastling (the rook's) and InvalidEnPassant
+}
+
+func (e *PositionError) Error() string {
+	colour := "White"
+	if e.Black {
+		colour = "Black"
+	}
+	var square string
+	if e.Square[0] >= 0 && e.Square[0] <= 7 && e.Square[1] >= 0 && e.Square[1] <= 7 {
+		square = squareName(e.Square[0], e.Square[1])
+	} else {
+		square = fmt.Sprint(e.Square)
+	}
+	switch e.Problem {
+	case InvalidPiece:
+		return "Invalid piece on " + square
+	case MissingKing:
+		return colour + " has no king"
+	case MultipleKings:
+		return colour + " has more than one king"
+	case PawnOnBackRank:
+		return colour + " pawn on the back rank at " + square
+	case OpponentInCheck:
+		return colour + " is in check, but it's not their move"
+	case InvalidCastling:
+		return colour + " can't castle with a rook on " + square
+	case InvalidEnPassant:
+		return colour + " pawn on " + square + " can't be taken en passant"
+	case TooManyPieces:
+		return colour + " has too many pieces"
+	}
+	return "Invalid position"
+}
+
+// PositionErrors are every reason a position is invalid.
+type PositionErrors []*PositionError
+
+func (errs PositionErrors) Error() string {
+	s := make([]string, len(errs))
+	for i, err := range errs {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Validate checks the position could have been reached in a game, and returns PositionErrors describing everything
+// wrong with it if not. Positions from outside (ie: set on Board directly) should be validated before being searched.
+func (cb *Chessboard) Validate() error {
+	var errs PositionErrors
+	report := func(problem Problem, black bool, x, y int8) {
+		errs = append(errs, &PositionError{Problem: problem, Black: black, Square: [2]int8{x, y}})
+	}
+
+	// pieces
+	var (
+		counts  [2][6]int
+		invalid bool
+	)
+	for y := range cb.Board {
+		for x, p := range cb.Board[y] {
+			if p == 0 {
+				continue
+			}
+			if !isPiece(p) {
+				report(InvalidPiece, false, int8(x), int8(y))
+				invalid = true
+				continue
+			}
+			i := pieceIndex(p)
+			counts[i/6][i%6]++
+			if i%6 == pawnKind && (y == 0 || y == 7) {
+				report(PawnOnBackRank, IsBlack(p), int8(x), int8(y))
+			}
+		}
+	}
+	if invalid {
+		// the rest of the checks need real pieces
+		return errs
+	}
+	_, antichess := cb.Variant.(Antichess)
+	_, crazyhouse := cb.Variant.(Crazyhouse)
+	for c, black := range [2]bool{false, true} {
+		switch kings := counts[c][kingKind]; {
+		case antichess:
+		case kings == 0:
+			// in Atomic, a king is only missing once the game is won
+			if _, atomic := cb.Variant.(Atomic); !atomic || counts[1-c][kingKind] == 0 {
+				report(MissingKing, black, -1, -1)
+			}
+		case kings > 1:
+			report(MultipleKings, black, -1, -1)
+		}
+		if !crazyhouse && tooManyPieces(counts[c], antichess) {
+			report(TooManyPieces, black, -1, -1)
+		}
+	}
+	if crazyhouse {
+		// pieces change sides, so only the totals are limited
+		var pawns, others int
+		for c := range counts {
+			for i := queenKind; i <= pawnKind; i++ {
+				n := counts[c][i] + cb.pockets[c][i-1]
+				if i == pawnKind {
+					pawns += n
+				} else {
+					others += n
+				}
+			}
+		}
+		if pawns > 16 || pawns+others > 30 {
+			report(TooManyPieces, false, -1, -1)
+		}
+	}
+
+	// the side which just moved can't have left itself in check
+	synced := cb.Copy()
+	synced.Sync()
+	if synced.IsCheck(!cb.BlackMove) {
+		report(OpponentInCheck, !cb.BlackMove, -1, -1)
+	}
+
+	// castling rights
+	for _, black := range [2]bool{false, true} {
+		y, king, rook := int8(7), WhiteKing, WhiteRook
+		if black {
+			y, king, rook = 0, BlackKing, BlackRook
+		}
+		kingX := cb.homeKing(black)
+		if !cb.Chess960 && kingX != 4 {
+			kingX = -1
+		}
+		for _, left := range [2]bool{false, true} {
+			if *cb.cantCastle(black, left) {
+				continue
+			}
+			x := cb.CastleFile(black, left)
+			if x < 0 || x > 7 || kingX < 0 || cb.Board[y][kingX] != king || cb.Board[y][x] != rook || (x < kingX) != left {
+				report(InvalidCastling, black, x, y)
+			}
+		}
+	}
+
+	// en passant, which must be a pawn of the side which just moved, having passed over two empty squares
+	if ep := cb.CanBeEnPassant; ep != nil {
+		x, y, pawn, rank, dir := ep[0], ep[1], BlackPawn, int8(3), int8(-1)
+		if cb.BlackMove {
+			pawn, rank, dir = WhitePawn, 4, 1
+		}
+		if x < 0 || x > 7 || y != rank || cb.Board[y][x] != pawn || cb.Board[y+dir][x] != 0 || cb.Board[y+2*dir][x] != 0 {
+			report(InvalidEnPassant, !cb.BlackMove, x, y)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// tooManyPieces returns true if a colour has more pieces of each kind (counted by kind) than it could have, pieces
+// beyond the starting ones needing a promoted pawn each. Extra kings are only counted if kings can be promoted to.
+func tooManyPieces(counts [6]int, kings bool) bool {
+	if counts[pawnKind] > 8 {
+		return true
+	}
+	promoted := 0
+	for i, start := range [6]int{1, 1, 2, 2, 2, 8} {
+		if (i != kingKind || kings) && i != pawnKind && counts[i] > start {
+			promoted += counts[i] - start
+		}
+	}
+	return promoted > 8-counts[pawnKind]
+}
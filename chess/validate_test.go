@@ -0,0 +1,60 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	for _, pos := range perftPositions {
+		cb, err := ParseFEN(pos.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cb.Validate(); err != nil {
+			t.Errorf("%s: %v", pos.name, err)
+		}
+	}
+
+	for _, test := range []struct {
+		fen  string
+		want []Problem
+	}{
+		{"8/8/8/8/8/8/8/4K3 w - - 0 1", []Problem{MissingKing}},
+		{"4k3/8/8/8/8/8/8/3KK3 w - - 0 1", []Problem{MultipleKings}},
+		{"P3k3/8/8/8/8/8/8/4K2p w - - 0 1", []Problem{PawnOnBackRank, PawnOnBackRank}},
+		{"4k3/8/8/8/8/8/8/4R1K1 w - - 0 1", []Problem{OpponentInCheck}},
+		{"4k3/8/8/8/8/8/8/4K3 w K - 0 1", []Problem{InvalidCastling}},
+		{"4k3/8/8/8/8/4K3/8/7R w K - 0 1", []Problem{InvalidCastling}},
+		{"4k3/8/8/8/4P3/8/4P3/4K3 b - e3 0 1", []Problem{InvalidEnPassant}},
+		{"4k3/8/8/8/8/P7/PPPPPPPP/4K3 w - - 0 1", []Problem{TooManyPieces}},
+		{"4k3/8/8/8/8/8/PPPPPPPP/QQ2K3 w - - 0 1", []Problem{TooManyPieces}},
+	} {
+		cb, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs, _ := cb.Validate().(PositionErrors)
+		if len(errs) != len(test.want) {
+			t.Errorf("%s: Validate() = %v, want %v", test.fen, errs, test.want)
+			continue
+		}
+		for i, err := range errs {
+			if err.Problem != test.want[i] {
+				t.Errorf("%s: Validate() = %v, want %v", test.fen, errs, test.want)
+				break
+			}
+		}
+	}
+
+	cb := NewChessboard()
+	cb.Board[4][4] = 'x'
+	if errs, _ := cb.Validate().(PositionErrors); len(errs) != 1 || errs[0].Problem != InvalidPiece || errs[0].Square != [2]int8{4, 4} {
+		t.Errorf("Validate() = %v with an invalid piece on e4", errs)
+	}
+	cb = NewChessboard()
+	cb.CanBeEnPassant = &[2]int8{9, 4}
+	cb.BlackMove = true
+	if errs, _ := cb.Validate().(PositionErrors); len(errs) != 1 || errs[0].Problem != InvalidEnPassant {
+		t.Errorf("Validate() = %v with an en passant square off the board", errs)
+	}
+}
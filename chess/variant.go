@@ -0,0 +1,242 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "math/bits"
+
+// Result is the outcome of a game.
+type Result int
+
+const (
+	Unfinished Result = iota
+	WhiteWins
+	BlackWins
+	Draw
+)
+
+// Variant changes the rules of chess. A Chessboard with a nil Variant plays standard chess. Variants embed Standard,
+// and override the rules they change.
+type Variant interface {
+	// Name returns the variant's name (ie: "King of the Hill").
+	Name() string
+	// IsCheck returns whether the colour is in check.
+	IsCheck(cb *Chessboard, black bool) bool
+	// Legal returns whether the position is allowed once the colour has moved. In standard chess it isn't if the
+	// colour left its king in check.
+	Legal(cb *Chessboard, black bool) bool
+	// Record is called by NewMove, and returns m along with anything Unmake will need to undo the variant's effects.
+	Record(cb *Chessboard, m Move) Move
+	// AfterMove is called by Make once the pieces have moved, to apply any other effects of the move.
+	AfterMove(cb *Chessboard, m Move)
+	// UndoMove is called by Unmake before the pieces move back, to undo AfterMove.
+	UndoMove(cb *Chessboard, m Move)
+	// Restrict is given every move a colour could make, and returns those the variant allows, reusing moves.
+	Restrict(cb *Chessboard, moves []Move) []Move
+	// Result returns who has won by the variant's own rules, or Unfinished. It's checked before checkmate and
+	// stalemate.
+	Result(cb *Chessboard) Result
+}
+
+// Standard plays by the usual rules. It's embedded by variants, so they only need to implement the rules they
+// change.
+type Standard struct{}
+
+func (Standard) Name() string                                 { return "Standard" }
+func (Standard) IsCheck(cb *Chessboard, black bool) bool      { return cb.kingAttacked(black) }
+func (Standard) Legal(cb *Chessboard, black bool) bool        { return !cb.kingAttacked(black) }
+func (Standard) Record(cb *Chessboard, m Move) Move           { return m }
+func (Standard) AfterMove(cb *Chessboard, m Move)             {}
+func (Standard) UndoMove(cb *Chessboard, m Move)              {}
+func (Standard) Restrict(cb *Chessboard, moves []Move) []Move { return moves }
+func (Standard) Result(cb *Chessboard) Result                 { return Unfinished }
+
+// VariantResult returns who has won by the board's variant's own rules, or Unfinished.
+func (cb *Chessboard) VariantResult() Result {
+	if cb.Variant == nil {
+		return Unfinished
+	}
+	return cb.Variant.Result(cb)
+}
+
+// winner returns the result of the colour winning.
+func winner(black bool) Result {
+	if black {
+		return BlackWins
+	}
+	return WhiteWins
+}
+
+// KingOfTheHill is won by checkmate, or by getting your king to one of the four centre squares.
+type KingOfTheHill struct{ Standard }
+
+// hill is d4, e4, d5 and e5.
+var hill = bit(3, 3) | bit(4, 3) | bit(3, 4) | bit(4, 4)
+
+func (KingOfTheHill) Name() string { return "King of the Hill" }
+
+func (KingOfTheHill) Result(cb *Chessboard) Result {
+	for _, black := range [2]bool{false, true} {
+		if cb.pieces[colour(black)*6+kingKind]&hill != 0 {
+			return winner(black)
+		}
+	}
+	return Unfinished
+}
+
+// ThreeCheck is won by checkmate, or by checking the opposing king three times.
+type ThreeCheck struct{ Standard }
+
+func (ThreeCheck) Name() string { return "Three-check" }
+
+func (ThreeCheck) AfterMove(cb *Chessboard, m Move) {
+	if cb.kingAttacked(cb.BlackMove) {
+		cb.Checks[colour(!cb.BlackMove)]++
+	}
+}
+
+func (ThreeCheck) UndoMove(cb *Chessboard, m Move) {
+	if cb.kingAttacked(cb.BlackMove) {
+		cb.Checks[colour(!cb.BlackMove)]--
+	}
+}
+
+func (ThreeCheck) Result(cb *Chessboard) Result {
+	for _, black := range [2]bool{false, true} {
+		if cb.Checks[colour(black)] >= 3 {
+			return winner(black)
+		}
+	}
+	return Unfinished
+}
+
+// Atomic is chess where every capture causes an explosion, removing the capturing piece and every piece but pawns
+// around it. It's won by checkmate or by blowing up the opposing king. Kings can't capture, and a king next to the
+// opposing king can't be checked.
+type Atomic struct{ Standard }
+
+// aroundDirections are the squares around an explosion, in the order they're stored in Move.Exploded.
+var aroundDirections = [8][2]int8{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+
+func (Atomic) Name() string { return "Atomic" }
+
+func (Atomic) IsCheck(cb *Chessboard, black bool) bool {
+	king, enemyKing := cb.pieces[colour(black)*6+kingKind], cb.pieces[colour(!black)*6+kingKind]
+	if king == 0 {
+		return false
+	}
+	sq := bits.TrailingZeros64(king)
+	if kingAttacks[sq]&enemyKing != 0 {
+		return false
+	}
+	return cb.attackers(sq, !black, cb.occupied())&^enemyKing != 0
+}
+
+func (a Atomic) Legal(cb *Chessboard, black bool) bool {
+	if cb.pieces[colour(black)*6+kingKind] == 0 {
+		return false
+	}
+	// blowing up the opposing king wins, even if in check
+	return cb.pieces[colour(!black)*6+kingKind] == 0 || !a.IsCheck(cb, black)
+}
+
+func (Atomic) Record(cb *Chessboard, m Move) Move {
+	if m.Captured == 0 {
+		return m
+	}
+	land := cb.landing(m.From, m.To, m.Type)
+	m.Exploded[8] = cb.Board[m.From[1]][m.From[0]]
+	if m.Promotion != 0 {
+		m.Exploded[8] = m.Promotion
+	}
+	for i, d := range aroundDirections {
+		x, y := land[0]+d[0], land[1]+d[1]
+		if x < 0 || x > 7 || y < 0 || y > 7 || (x == m.From[0] && y == m.From[1]) {
+			continue
+		}
+		if p := cb.Board[y][x]; p != WhitePawn && p != BlackPawn {
+			m.Exploded[i] = p
+		}
+	}
+	return m
+}
+
+func (Atomic) AfterMove(cb *Chessboard, m Move) {
+	if m.Captured == 0 {
+		return
+	}
+	land := cb.landing(m.From, m.To, m.Type)
+	cb.set(land[0], land[1], 0)
+	for i, d := range aroundDirections {
+		if p := m.Exploded[i]; p != 0 {
+			x, y := land[0]+d[0], land[1]+d[1]
+			if p == WhiteRook || p == BlackRook {
+				cb.loseCastling(x, y)
+			} else if p == WhiteKing || p == BlackKing {
+				*cb.cantCastle(IsBlack(p), true), *cb.cantCastle(IsBlack(p), false) = true, true
+			}
+			cb.set(x, y, 0)
+		}
+	}
+}
+
+func (Atomic) UndoMove(cb *Chessboard, m Move) {
+	if m.Captured == 0 {
+		return
+	}
+	land := cb.landing(m.From, m.To, m.Type)
+	cb.set(land[0], land[1], m.Exploded[8])
+	for i, d := range aroundDirections {
+		if p := m.Exploded[i]; p != 0 {
+			cb.set(land[0]+d[0], land[1]+d[1], p)
+		}
+	}
+}
+
+func (Atomic) Result(cb *Chessboard) Result {
+	for _, black := range [2]bool{false, true} {
+		if cb.pieces[colour(black)*6+kingKind] == 0 {
+			return winner(!black)
+		}
+	}
+	return Unfinished
+}
+
+// Antichess is won by losing every piece, or by having no legal moves. Captures must be made when possible, the
+// king is an ordinary piece which can be taken, and there's no check or castling.
+type Antichess struct{ Standard }
+
+func (Antichess) Name() string                            { return "Antichess" }
+func (Antichess) IsCheck(cb *Chessboard, black bool) bool { return false }
+func (Antichess) Legal(cb *Chessboard, black bool) bool   { return true }
+
+func (Antichess) Restrict(cb *Chessboard, moves []Move) []Move {
+	captures := false
+	for _, m := range moves {
+		if m.Captured != 0 {
+			captures = true
+			break
+		}
+	}
+	n := 0
+	for _, m := range moves {
+		if m.Type == CastleLeft || m.Type == CastleRight || (captures && m.Captured == 0) {
+			continue
+		}
+		moves[n] = m
+		n++
+	}
+	return moves[:n]
+}
+
+func (Antichess) Result(cb *Chessboard) Result {
+	for _, black := range [2]bool{false, true} {
+		if cb.colours[colour(black)] == 0 {
+			return winner(black)
+		}
+	}
+	var buf [maxMoves]Move
+	if len(cb.appendLegalMoves(buf[:0], cb.BlackMove)) == 0 {
+		return winner(cb.BlackMove)
+	}
+	return Unfinished
+}
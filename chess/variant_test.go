@@ -0,0 +1,218 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+func TestAtomicPerft(t *testing.T) {
+	for _, pos := range []struct {
+		fen   string
+		nodes []uint64
+	}{
+		{StartingFEN, []uint64{20, 400, 8902, 197326}},
+	} {
+		cb, err := ParseFEN(pos.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cb.Variant = Atomic{}
+		before := cb.FEN()
+		for i, want := range pos.nodes {
+			if testing.Short() && want > 100000 {
+				break
+			}
+			if got := cb.Perft(i + 1); got != want {
+				t.Errorf("%s depth %d: got %d nodes, want %d", pos.fen, i+1, got, want)
+			}
+		}
+		if fen := cb.FEN(); fen != before {
+			t.Errorf("position changed after perft: %s", fen)
+		}
+	}
+}
+
+func TestAtomic(t *testing.T) {
+	cb, err := ParseFEN("rnbqkbnr/ppp2ppp/8/3pp3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.Variant = Atomic{}
+	before := cb.FEN()
+	m := cb.NewMove([2]int8{5, 5}, [2]int8{4, 3}, RegularMove, 0) // Nxe5
+	cb.Make(m)
+	if cb.Board[3][4] != 0 || cb.Board[5][5] != 0 {
+		t.Errorf("capturing knight survived: %s", cb.FEN())
+	}
+	if cb.Board[3][3] != BlackPawn {
+		t.Errorf("pawn next to the explosion was removed: %s", cb.FEN())
+	}
+	cb.Unmake(m)
+	if fen := cb.FEN(); fen != before {
+		t.Errorf("Unmake() left %s, want %s", fen, before)
+	}
+
+	// Qxf7 blows up the black king, even though the king could otherwise recapture
+	cb = NewChessboard()
+	cb.Variant = Atomic{}
+	for _, san := range []string{"e3", "a6", "Qh5", "a5", "Qxf7"} {
+		if err := cb.DoSAN(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r := cb.VariantResult(); r != WhiteWins {
+		t.Errorf("VariantResult() = %d after exploding the black king, want WhiteWins", r)
+	}
+	if moves := cb.LegalMoves(true); len(moves) != 0 {
+		t.Errorf("black has %d moves without a king", len(moves))
+	}
+}
+
+func TestKingOfTheHill(t *testing.T) {
+	cb, err := ParseFEN("4k3/8/8/8/8/4K3/8/8 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.Variant = KingOfTheHill{}
+	if cb.IsInsufficientMaterial() {
+		t.Error("bare kings are insufficient material in King of the Hill")
+	}
+	if err := cb.DoSAN("Ke4"); err != nil {
+		t.Fatal(err)
+	}
+	if r := cb.VariantResult(); r != WhiteWins {
+		t.Errorf("VariantResult() = %d with the white king on e4, want WhiteWins", r)
+	}
+}
+
+func TestThreeCheck(t *testing.T) {
+	cb := NewChessboard()
+	cb.Variant = ThreeCheck{}
+	for _, san := range []string{"e4", "e5", "Bc4", "Nc6", "Bxf7+", "Kxf7", "Qh5+", "g6", "Qxg6+"} {
+		if cb.VariantResult() != Unfinished {
+			t.Fatalf("game over before %s", san)
+		}
+		if err := cb.DoSAN(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cb.Checks != [2]int{3, 0} {
+		t.Errorf("Checks = %v, want [3 0]", cb.Checks)
+	}
+	if r := cb.VariantResult(); r != WhiteWins {
+		t.Errorf("VariantResult() = %d after three checks, want WhiteWins", r)
+	}
+
+	m := cb.LegalMoves(true)
+	if len(m) != 0 {
+		t.Errorf("black has %d moves after losing", len(m))
+	}
+
+	// the checks given are part of the position
+	hash := cb.Hash()
+	cb.Sync()
+	if cb.Hash() != hash {
+		t.Errorf("key is %016x after three checks, want %016x", hash, cb.Hash())
+	}
+	cb.Checks = [2]int{2, 0}
+	cb.Sync()
+	if cb.Hash() == hash {
+		t.Error("key is the same after two checks as after three")
+	}
+
+	// a check given by completing a promotion is counted
+	cb, _ = ParseFEN("7k/P7/8/8/8/8/8/4K3 w - - 0 1")
+	cb.Variant = ThreeCheck{}
+	cb.Sync()
+	g := NewGame(cb)
+	g.DoMove([2]int8{0, 1}, [2]int8{0, 0})
+	if !g.PromotePawn(0, 0, WhiteRook) || g.Checks != [2]int{1, 0} {
+		t.Fatalf("Checks = %v after promoting with check, want [1 0]", g.Checks)
+	}
+	hash = g.Hash()
+	g.Sync()
+	if g.Hash() != hash {
+		t.Errorf("key is %016x after promoting with check, want %016x", hash, g.Hash())
+	}
+	if g.GoTo(0); g.Checks != [2]int{0, 0} {
+		t.Errorf("Checks = %v after taking back the promotion, want [0 0]", g.Checks)
+	}
+	if g.GoTo(1); g.Checks != [2]int{1, 0} || g.Hash() != hash {
+		t.Errorf("Checks = %v after replaying the promotion, want [1 0]", g.Checks)
+	}
+}
+
+func TestAntichessPerft(t *testing.T) {
+	cb := NewChessboard()
+	cb.Variant = Antichess{}
+	for i, want := range []uint64{20, 400, 8067, 153299} {
+		if testing.Short() && want > 100000 {
+			break
+		}
+		if got := cb.Perft(i + 1); got != want {
+			t.Errorf("depth %d: got %d nodes, want %d", i+1, got, want)
+		}
+	}
+}
+
+func TestAntichess(t *testing.T) {
+	cb := NewChessboard()
+	cb.Variant = Antichess{}
+	for _, san := range []string{"e3", "b5"} {
+		if err := cb.DoSAN(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	moves := cb.LegalMoves(false)
+	if len(moves) != 1 || moves[0].String() != "f1b5" {
+		t.Errorf("LegalMoves() = %v, want only the capture f1b5", moves)
+	}
+	if cb.IsLegal([2]int8{3, 6}, [2]int8{3, 4}, RegularMove) {
+		t.Error("d4 is legal while a capture is available")
+	}
+	if moves, _, _, _ := cb.PossibleMoves(SquareAt(3, 6)); len(moves) != 0 {
+		t.Errorf("PossibleMoves() = %v while a capture is available", moves)
+	}
+
+	// the king can be taken, and losing the last piece wins
+	cb, err := ParseFEN("8/8/8/8/8/8/1k6/K7 b - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.Variant = Antichess{}
+	if err := cb.DoSAN("Kxa1"); err != nil {
+		t.Fatal(err)
+	}
+	if r := cb.VariantResult(); r != WhiteWins {
+		t.Errorf("VariantResult() = %d after losing every piece, want WhiteWins", r)
+	}
+
+	// having no moves wins
+	cb, _ = ParseFEN("8/8/8/8/8/p7/P7/8 w - - 0 1")
+	cb.Variant = Antichess{}
+	if r := cb.VariantResult(); r != WhiteWins {
+		t.Errorf("VariantResult() = %d when stalemated, want WhiteWins", r)
+	}
+
+	// a pawn can be promoted to a king
+	cb, _ = ParseFEN("8/P7/8/8/8/8/8/7k w - - 0 1")
+	if moves := cb.LegalMoves(false); len(moves) != 4 {
+		t.Errorf("LegalMoves() = %v in standard chess, want 4 promotions", moves)
+	}
+	cb.Variant = Antichess{}
+	moves = cb.LegalMoves(false)
+	if len(moves) != 5 || moves[4].String() != "a7a8k" {
+		t.Errorf("LegalMoves() = %v, want 5 promotions including a7a8k", moves)
+	}
+	if got := cb.Perft(2); got != 15 {
+		t.Errorf("Perft(2) = %d, want 15", got)
+	}
+	if san, err := cb.SAN(moves[4].From, moves[4].To, RegularMove, WhiteKing); err != nil || san != "a8=K" {
+		t.Errorf("SAN(a7a8k) = %q, %v, want a8=K", san, err)
+	}
+	if err := cb.DoSAN("a8=K"); err != nil || cb.Board[0][0] != WhiteKing {
+		t.Fatalf("DoSAN(a8=K) = %v, gave %s", err, cb.FEN())
+	}
+	if err := cb.Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chess
+
+import "testing"
+
+// polyglotKeys are the reference keys from the Polyglot book format specification, with the moves leading to them.
+var polyglotKeys = []struct {
+	moves []string
+	fen   string
+	key   uint64
+}{
+	{nil, StartingFEN, 0x463b96181691fc9c},
+	{[]string{"e4"}, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", 0x823c9b50fd114196},
+	{[]string{"e4", "d5"}, "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", 0x0756b94461c50fb0},
+	{[]string{"e4", "d5", "e5"}, "rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2", 0x662fafb965db29d4},
+	{[]string{"e4", "d5", "e5", "f5"}, "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", 0x22a48b5a8e47ff78},
+	{[]string{"e4", "d5", "e5", "f5", "Ke2"}, "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR b kq - 0 3", 0x652a607ca3f242c1},
+	{[]string{"e4", "d5", "e5", "f5", "Ke2", "Kf7"}, "rnbq1bnr/ppp1pkpp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR w - - 0 4", 0x00fdd303c946bdd9},
+	{[]string{"a4", "b5", "h4", "b4", "c4"}, "rnbqkbnr/p1pppppp/8/8/PpP4P/8/1P1PPPP1/RNBQKBNR b KQkq c3 0 3", 0x3c8123ea7b067637},
+	{[]string{"a4", "b5", "h4", "b4", "c4", "bxc3", "Ra3"}, "rnbqkbnr/p1pppppp/8/8/P6P/R1p5/1P1PPPP1/1NBQKBNR b Kkq - 0 4", 0x5c3f9b829b279560},
+}
+
+func TestPolyglotKeys(t *testing.T) {
+	for _, pos := range polyglotKeys {
+		cb, err := ParseFEN(pos.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cb.Hash() != pos.key {
+			t.Errorf("%s: parsed key is %016x, want %016x", pos.fen, cb.Hash(), pos.key)
+		}
+
+		cb = NewChessboard()
+		for _, san := range pos.moves {
+			if err := cb.DoSAN(san); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if cb.Hash() != pos.key {
+			t.Errorf("%v: played key is %016x, want %016x", pos.moves, cb.Hash(), pos.key)
+		}
+	}
+}
+
+// checkHash walks every line depth moves deep, checking the incrementally updated hash matches a full recompute.
+func checkHash(t *testing.T, cb *Chessboard, depth int) {
+	want := cb.Hash()
+	cb.Sync()
+	if cb.Hash() != want {
+		t.Fatalf("%s: incremental key is %016x, recomputed key is %016x", cb.FEN(), want, cb.Hash())
+	}
+	if depth == 0 {
+		return
+	}
+	for _, m := range cb.LegalMoves(cb.BlackMove) {
+		cb.Make(m)
+		checkHash(t, cb, depth-1)
+		cb.Unmake(m)
+		if cb.Hash() != want {
+			t.Fatalf("%s: key is %016x after taking back %s, want %016x", cb.FEN(), cb.Hash(), m, want)
+		}
+	}
+}
+
+func TestIncrementalHash(t *testing.T) {
+	for _, pos := range perftPositions {
+		t.Run(pos.name, func(t *testing.T) {
+			cb, err := ParseFEN(pos.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkHash(t, cb, 2)
+		})
+	}
+}
+
+func TestNoHistory(t *testing.T) {
+	// a board which wasn't made by NewChessboard or ParseFEN has no history of positions
+	cb := new(Chessboard)
+	cb.Board[0][0], cb.Board[0][7], cb.Board[7][4] = WhitePawn, BlackKing, WhiteKing
+	cb.Sync()
+	if !cb.PromotePawn(0, 0, WhiteQueen) {
+		t.Fatal("PromotePawn() failed")
+	}
+	if n := cb.Repetitions(); n != 1 {
+		t.Errorf("Repetitions() = %d, want 1", n)
+	}
+	got := cb.Hash()
+	cb.Sync()
+	if got != cb.Hash() {
+		t.Errorf("key is %016x after promoting, want %016x", got, cb.Hash())
+	}
+}
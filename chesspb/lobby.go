@@ -0,0 +1,33 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chesspb
+
+import "fmt"
+
+// Describe returns a time control in the usual notation of minutes plus seconds of increment (ie: "5+3"), followed
+// by the kind of delay if it isn't an increment (ie: "5+3 Bronstein"), or "no clock".
+func (tc *TimeControl) Describe() string {
+	if tc.GetBase() == 0 {
+		return "no clock"
+	}
+	s := fmt.Sprintf("%d+%d", tc.Base/60, tc.Increment)
+	if tc.Base%60 != 0 {
+		s = fmt.Sprintf("%ds+%d", tc.Base, tc.Increment)
+	}
+	switch tc.Kind {
+	case TimeControl_Bronstein:
+		s += " Bronstein"
+	case TimeControl_SimpleDelay:
+		s += " delay"
+	}
+	return s
+}
+
+// Describe returns a readable description of what a challenge is to play (ie: "Crazyhouse 960, 5+3").
+func (c *Challenge) Describe() string {
+	s := c.Variant.Describe()
+	if c.Chess960 {
+		s += " 960"
+	}
+	return s + ", " + c.TimeControl.Describe()
+}
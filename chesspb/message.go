@@ -6,11 +6,16 @@ import (
 	"bufio"
 	"encoding/binary"
 	"errors"
+	"io"
 	//	"github.com/TheDiscordian/speedychess/chess"
 	. "github.com/TheDiscordian/speedychess/flags"
 	"google.golang.org/protobuf/proto"
 )
 
+// MAX_CLIENT_RECEIVE is the largest message a client will receive, anything bigger is treated as an error rather than
+// allocated.
+const MAX_CLIENT_RECEIVE = 1 << 16
+
 // ReadMessage reads a packet from r, and stores the data in m (if valid) or returns an error. Server can
 // receive up to 256 bytes, while a client has no receive limit.
 func ReadMessage(r *bufio.Reader, m *proto.Message) error {
@@ -39,6 +44,9 @@ func ReadMessage(r *bufio.Reader, m *proto.Message) error {
 		if err != nil {
 			return err
 		}
+		if size64 > MAX_CLIENT_RECEIVE {
+			return errors.New("Message too large")
+		}
 		size = int(size64)
 	}
 	if size == 0 {
@@ -46,12 +54,8 @@ func ReadMessage(r *bufio.Reader, m *proto.Message) error {
 	}
 
 	recv := make([]byte, size, size)
-	for i := 0; i < size; i++ {
-		b, err := r.ReadByte()
-		if err != nil {
-			return err
-		}
-		recv[i] = b
+	if _, err := io.ReadFull(r, recv); err != nil {
+		return err
 	}
 	err = proto.Unmarshal(recv, *m)
 	return err
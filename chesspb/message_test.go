@@ -0,0 +1,40 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chesspb
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func FuzzReadMessage(f *testing.F) {
+	for _, m := range []proto.Message{
+		new(Ping),
+		&Join{Player: true},
+		&NewGame{Chess960: true, Variant: Variant_Crazyhouse},
+		&Move{Fx: 4, Fy: 6, Tx: 4, Ty: 4},
+		&Move{Fx: 200, Fy: 6, Tx: 4, Ty: 9, MoveType: Move_Drop, Piece: -1},
+		&Promote{X: 0, Y: 0, To: 0x2655},
+		&Error{Msg: "That's not legal."},
+	} {
+		data, err := BuildMessage(m)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte{byte(MoveMsg), 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bufio.NewReader(bytes.NewReader(data))
+		var m proto.Message
+		for ReadMessage(r, &m) == nil {
+			// what the server does with a move must be safe too
+			if move, ok := m.(*Move); ok && move.From().Valid() {
+				_ = move.From().Coords()
+			}
+		}
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chesspb
+
+import "github.com/TheDiscordian/speedychess/chess"
+
+// NewMove returns the message for a move from one square to another. For a drop, from may be chess.NoSquare.
+func NewMove(from, to chess.Square, movet chess.MoveType) *Move {
+	if !from.Valid() {
+		from = to
+	}
+	return &Move{Fx: uint32(from.X()), Fy: uint32(from.Y()), Tx: uint32(to.X()), Ty: uint32(to.Y()), MoveType: Move_MoveType(movet)}
+}
+
+// From returns the square the move starts from, or chess.NoSquare if it's not on the board.
+func (m *Move) From() chess.Square {
+	return square(m.Fx, m.Fy)
+}
+
+// To returns the square the move ends on, or chess.NoSquare if it's not on the board. For en passant, it's the
+// square of the pawn being taken.
+func (m *Move) To() chess.Square {
+	return square(m.Tx, m.Ty)
+}
+
+// Square returns the square of the pawn being promoted, or chess.NoSquare if it's not on the board.
+func (p *Promote) Square() chess.Square {
+	return square(p.X, p.Y)
+}
+
+// square returns the square at x, y, or chess.NoSquare if it's not on the board.
+func square(x, y uint32) chess.Square {
+	if x > 7 || y > 7 {
+		return chess.NoSquare
+	}
+	return chess.SquareAt(int8(x), int8(y))
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chesspb
+
+import "github.com/TheDiscordian/speedychess/chess"
+
+// Describe returns a readable description of why a game ended (ie: "threefold repetition").
+func (r GameComplete_Reason) Describe() string {
+	switch r {
+	case GameComplete_FiftyMoveRule:
+		return "the fifty-move rule"
+	case GameComplete_SeventyFiveMoveRule:
+		return "the seventy-five-move rule"
+	case GameComplete_ThreefoldRepetition:
+		return "threefold repetition"
+	case GameComplete_FivefoldRepetition:
+		return "fivefold repetition"
+	case GameComplete_InsufficientMaterial:
+		return "insufficient material"
+	case GameComplete_KingOfTheHill:
+		return "reaching the centre"
+	case GameComplete_ThreeCheck:
+		return "three checks"
+	case GameComplete_Explosion:
+		return "explosion"
+	case GameComplete_GiveAway:
+		return "running out of pieces or moves"
+	case GameComplete_Timeout:
+		return "timeout"
+	}
+	return "no legal moves"
+}
+
+// NewGameComplete returns the message announcing how a game ended, or nil if it hasn't.
+func NewGameComplete(g *chess.Game) *GameComplete {
+	m := new(GameComplete)
+	switch g.Result {
+	case chess.Unfinished:
+		return nil
+	case chess.WhiteWins:
+		m.Result = GameComplete_WhiteWin
+	case chess.BlackWins:
+		m.Result = GameComplete_BlackWin
+	case chess.Draw:
+		m.Result = GameComplete_Draw
+	}
+	switch g.Termination {
+	case chess.Stalemate:
+		m.Result = GameComplete_Stalemate
+	case chess.VariantRules:
+		m.Reason = VariantOf(g.Variant).Reason()
+	case chess.InsufficientMaterial:
+		m.Reason = GameComplete_InsufficientMaterial
+	case chess.FiftyMoveRule:
+		m.Reason = GameComplete_FiftyMoveRule
+	case chess.SeventyFiveMoveRule:
+		m.Reason = GameComplete_SeventyFiveMoveRule
+	case chess.ThreefoldRepetition:
+		m.Reason = GameComplete_ThreefoldRepetition
+	case chess.FivefoldRepetition:
+		m.Reason = GameComplete_FivefoldRepetition
+	case chess.Timeout:
+		m.Reason = GameComplete_Timeout
+	}
+	return m
+}
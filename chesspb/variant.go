@@ -0,0 +1,71 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package chesspb
+
+import "github.com/TheDiscordian/speedychess/chess"
+
+// Chess returns the rules for playing the variant, nil for standard chess.
+func (v Variant) Chess() chess.Variant {
+	switch v {
+	case Variant_KingOfTheHill:
+		return chess.KingOfTheHill{}
+	case Variant_ThreeCheck:
+		return chess.ThreeCheck{}
+	case Variant_Atomic:
+		return chess.Atomic{}
+	case Variant_Crazyhouse:
+		return chess.Crazyhouse{}
+	case Variant_Antichess:
+		return chess.Antichess{}
+	}
+	return nil
+}
+
+// VariantOf returns the variant played by a chess.Variant's rules, Standard for nil.
+func VariantOf(v chess.Variant) Variant {
+	switch v.(type) {
+	case chess.KingOfTheHill:
+		return Variant_KingOfTheHill
+	case chess.ThreeCheck:
+		return Variant_ThreeCheck
+	case chess.Atomic:
+		return Variant_Atomic
+	case chess.Crazyhouse:
+		return Variant_Crazyhouse
+	case chess.Antichess:
+		return Variant_Antichess
+	}
+	return Variant_Standard
+}
+
+// Reason returns why a game of the variant ended, when it was won by the variant's own rules.
+func (v Variant) Reason() GameComplete_Reason {
+	switch v {
+	case Variant_KingOfTheHill:
+		return GameComplete_KingOfTheHill
+	case Variant_ThreeCheck:
+		return GameComplete_ThreeCheck
+	case Variant_Atomic:
+		return GameComplete_Explosion
+	case Variant_Antichess:
+		return GameComplete_GiveAway
+	}
+	return GameComplete_NoMoves
+}
+
+// Describe returns the variant's name (ie: "King of the Hill").
+func (v Variant) Describe() string {
+	switch v {
+	case Variant_KingOfTheHill:
+		return "King of the Hill"
+	case Variant_ThreeCheck:
+		return "Three-check"
+	case Variant_Atomic:
+		return "Atomic"
+	case Variant_Crazyhouse:
+		return "Crazyhouse"
+	case Variant_Antichess:
+		return "Antichess"
+	}
+	return "Standard"
+}
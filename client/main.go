@@ -6,6 +6,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"strconv"
 	"syscall/js"
 	"time"
 	"unicode/utf8"
@@ -18,11 +19,13 @@ import (
 
 var (
 	C          *chesspb.Client
-	Game       *chess.Chessboard
+	Game       *chess.Game
 	Black      bool
-	MyTurn     bool
 	Promotion  *[2]int8
-	StoredMove []int8 // nil or len(2)
+	StoredMove []int8           // nil or len(2)
+	StoredDrop chess.Piece      // piece selected from the pocket, 0 if none
+	Clocks     [2]time.Duration // time left for white then black as of ClockSet, both 0 if there's no clock
+	ClockSet   time.Time
 )
 
 const (
@@ -102,7 +105,45 @@ func drawBoard(flip bool) string {
 			output = append(output, []rune("</tr>")...)
 		}
 	}
-	return "<table style=\"margin:-1em auto;padding-top:0px;cursor:pointer;table-layout: fixed;\">" + string(output) + "<br></table>"
+	return "<table style=\"margin:-1em auto;padding-top:0px;cursor:pointer;table-layout: fixed;\">" + string(output) + "<br></table>" + drawPocket(flip)
+}
+
+// drawPocket returns the pieces in the player's pocket when playing Crazyhouse, which can be clicked to drop them.
+func drawPocket(black bool) string {
+	if _, ok := Game.Variant.(chess.Crazyhouse); !ok {
+		return ""
+	}
+	pieces := []chess.Piece{chess.WhiteQueen, chess.WhiteRook, chess.WhiteBishop, chess.WhiteKnight, chess.WhitePawn}
+	if black {
+		pieces = []chess.Piece{chess.BlackQueen, chess.BlackRook, chess.BlackBishop, chess.BlackKnight, chess.BlackPawn}
+	}
+	output := ""
+	for _, p := range pieces {
+		if n := Game.Pocket(p); n > 0 {
+			output += fmt.Sprintf(`<td onclick="selectdrop(%d)"><span id="%s"></span>%d</td>`, p, string(p), n)
+		}
+	}
+	return `<table style="margin:1.5em auto 0;cursor:pointer;"><tr>` + output + `</tr></table>`
+}
+
+// selectDrop selects a piece from the pocket, highlighting where it can be dropped.
+func selectDrop(this js.Value, args []js.Value) interface{} {
+	document := js.Global().Get("document")
+	if Game == nil || Game.BlackMove != Black {
+		return nil
+	}
+	document.Call("getElementById", "chessboard").Set("innerHTML", drawBoard(Black))
+	StoredMove = nil
+	StoredDrop = chess.Piece(args[0].Int())
+	for _, m := range Game.LegalMoves(Black) {
+		if m.Type != chess.Drop || m.Dropped != StoredDrop {
+			continue
+		}
+		square := document.Call("getElementById", fmt.Sprintf("%dx%d", m.To[0], m.To[1]))
+		square.Set("style", "background-color:red;border:1px dashed;")
+		square.Set("red-drop", true)
+	}
+	return nil
 }
 
 func selectPromotion(this js.Value, args []js.Value) interface{} {
@@ -119,7 +160,7 @@ func selectPiece(this js.Value, args []js.Value) interface{} {
 
 	var movet chess.MoveType
 
-	if Game == nil || !MyTurn {
+	if Game == nil || Game.BlackMove != Black {
 		return nil
 	}
 	x, y := int8(args[0].Int()), int8(args[1].Int())
@@ -128,6 +169,15 @@ func selectPiece(this js.Value, args []js.Value) interface{} {
 	redEnPassant := tile.Get("red-enpassant").Truthy()
 	redCastleLeft := tile.Get("red-castleleft").Truthy()
 	redCastleRight := tile.Get("red-castleright").Truthy()
+	if tile.Get("red-drop").Truthy() && StoredDrop != 0 {
+		m := chesspb.NewMove(chess.NoSquare, chess.SquareAt(x, y), chess.Drop)
+		m.Piece = int32(StoredDrop)
+		C.Send(m)
+		StoredDrop = 0
+		document.Call("getElementById", "chessboard").Set("innerHTML", drawBoard(Black))
+		return nil
+	}
+	StoredDrop = 0
 	if (red || redEnPassant || redCastleLeft || redCastleRight) && StoredMove != nil {
 		var modifier int8
 		if redEnPassant {
@@ -142,7 +192,7 @@ func selectPiece(this js.Value, args []js.Value) interface{} {
 		} else if redCastleRight {
 			movet = chess.CastleRight
 		}
-		C.Send(&chesspb.Move{Fx: uint32(StoredMove[0]), Fy: uint32(StoredMove[1]), Tx: uint32(x), Ty: uint32(y + modifier), MoveType: chesspb.Move_MoveType(movet)})
+		C.Send(chesspb.NewMove(chess.SquareAt(StoredMove[0], StoredMove[1]), chess.SquareAt(x, y+modifier), movet))
 		StoredMove = nil
 		document.Call("getElementById", "chessboard").Set("innerHTML", drawBoard(Black))
 		return nil
@@ -154,9 +204,9 @@ func selectPiece(this js.Value, args []js.Value) interface{} {
 	}
 	StoredMove = []int8{x, y}
 
-	moves, enpassant, castleleft, castleright := Game.PossibleMoves(x, y)
+	moves, enpassant, castleleft, castleright := Game.PossibleMoves(chess.SquareAt(x, y))
 	for _, move := range moves {
-		square := document.Call("getElementById", fmt.Sprintf("%dx%d", move[0], move[1]))
+		square := document.Call("getElementById", fmt.Sprintf("%dx%d", move.X(), move.Y()))
 		square.Set("style", "background-color:red;border:1px dashed;")
 		square.Set("red", true)
 	}
@@ -167,42 +217,172 @@ func selectPiece(this js.Value, args []js.Value) interface{} {
 		} else {
 			modifier = -1
 		}
-		square := document.Call("getElementById", fmt.Sprintf("%dx%d", move[0], move[1]+modifier))
+		square := document.Call("getElementById", fmt.Sprintf("%dx%d", move.X(), move.Y()+modifier))
 		square.Set("style", "background-color:red;border:1px dashed;")
 		square.Set("red-enpassant", true)
 	}
 	if castleleft {
-		square := document.Call("getElementById", fmt.Sprintf("%dx%d", x-2, y))
+		target := castleTarget(x, y, true, moves)
+		square := document.Call("getElementById", fmt.Sprintf("%dx%d", target, y))
 		square.Set("style", "background-color:red;border:1px dashed;")
 		square.Set("red-castleleft", true)
 	}
 	if castleright {
-		square := document.Call("getElementById", fmt.Sprintf("%dx%d", x+2, y))
+		target := castleTarget(x, y, false, moves)
+		square := document.Call("getElementById", fmt.Sprintf("%dx%d", target, y))
 		square.Set("style", "background-color:red;border:1px dashed;")
 		square.Set("red-castleright", true)
 	}
 	return nil
 }
 
+// castleTarget returns the file to click to castle: where the king lands, unless the king is already there or could
+// move there normally (possible in Chess960), in which case it's the rook.
+func castleTarget(x, y int8, left bool, moves []chess.Square) int8 {
+	target := int8(6)
+	if left {
+		target = 2
+	}
+	if target == x {
+		return Game.CastleFile(Black, left)
+	}
+	for _, move := range moves {
+		if move == chess.SquareAt(target, y) {
+			return Game.CastleFile(Black, left)
+		}
+	}
+	return target
+}
+
 func newGame(this js.Value, args []js.Value) interface{} {
-	C.Send(new(chesspb.NewGame))
+	variant, chess960, tc := gameOptions()
+	C.Send(&chesspb.NewGame{Chess960: chess960, Variant: variant, TimeControl: tc})
 	return nil
 }
 
+// setClocks sets the time left on each clock, in milliseconds as sent by the server.
+func setClocks(white, black uint32) {
+	Clocks = [2]time.Duration{time.Duration(white) * time.Millisecond, time.Duration(black) * time.Millisecond}
+	ClockSet = time.Now()
+}
+
+// formatClock returns the time left on a clock (ie: "4:59").
+func formatClock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return fmt.Sprintf("%d:%02d", d/time.Minute, d%time.Minute/time.Second)
+}
+
+// drawClocks shows the time left on each clock, counting down the side to move's.
+func drawClocks() {
+	game := Game
+	if game == nil || Clocks == [2]time.Duration{} {
+		return
+	}
+	left := Clocks
+	if game.BlackMove {
+		left[1] -= time.Since(ClockSet)
+	} else {
+		left[0] -= time.Since(ClockSet)
+	}
+	js.Global().Get("document").Call("getElementById", "clocks").Set("innerText",
+		"White "+formatClock(left[0])+" | Black "+formatClock(left[1]))
+}
+
+// winReason describes how a game was won, if it wasn't by checkmate (ie: " by three checks").
+func winReason(r chesspb.GameComplete_Reason) string {
+	if r == chesspb.GameComplete_NoMoves {
+		return ""
+	}
+	return " by " + r.Describe()
+}
+
+// gameID returns the game ID entered, or 0 if there isn't one.
+func gameID() uint32 {
+	id, _ := strconv.ParseUint(js.Global().Get("document").Call("getElementById", "gameid").Get("value").String(), 10, 32)
+	return uint32(id)
+}
+
 func joinGame(this js.Value, args []js.Value) interface{} {
-	C.Send(&chesspb.Join{Player: true})
+	C.Send(&chesspb.Join{Player: true, Game: gameID()})
+	return nil
+}
+
+func createGame(this js.Value, args []js.Value) interface{} {
+	C.Send(&chesspb.Join{Player: true, Create: true})
 	return nil
 }
 
+// watchGame watches the game with the ID given, or the one entered.
 func watchGame(this js.Value, args []js.Value) interface{} {
-	C.Send(&chesspb.Join{Player: false})
+	id := gameID()
+	if len(args) > 0 {
+		id = uint32(args[0].Int())
+	}
+	C.Send(&chesspb.Join{Player: false, Game: id})
+	return nil
+}
+
+// gameOptions returns the variant and time control selected.
+func gameOptions() (variant chesspb.Variant, chess960 bool, tc *chesspb.TimeControl) {
+	document := js.Global().Get("document")
+	chess960 = document.Call("getElementById", "chess960").Get("checked").Bool()
+	v, _ := strconv.Atoi(document.Call("getElementById", "variant").Get("value").String())
+	minutes, _ := strconv.ParseUint(document.Call("getElementById", "minutes").Get("value").String(), 10, 32)
+	increment, _ := strconv.ParseUint(document.Call("getElementById", "increment").Get("value").String(), 10, 32)
+	kind, _ := strconv.Atoi(document.Call("getElementById", "clockkind").Get("value").String())
+	return chesspb.Variant(v), chess960, &chesspb.TimeControl{
+		Base:      uint32(minutes) * 60,
+		Increment: uint32(increment),
+		Kind:      chesspb.TimeControl_Kind(kind),
+	}
+}
+
+func createChallenge(this js.Value, args []js.Value) interface{} {
+	variant, chess960, tc := gameOptions()
+	C.Send(&chesspb.CreateChallenge{Variant: variant, Chess960: chess960, TimeControl: tc})
+	return nil
+}
+
+// play joins the matchmaking queue.
+func play(this js.Value, args []js.Value) interface{} {
+	variant, chess960, tc := gameOptions()
+	rating, _ := strconv.ParseUint(js.Global().Get("document").Call("getElementById", "rating").Get("value").String(), 10, 32)
+	C.Send(&chesspb.Queue{Rating: uint32(rating), Variant: variant, Chess960: chess960, TimeControl: tc})
+	LogToConsole("Looking for an opponent...")
+	return nil
+}
+
+func cancelPlay(this js.Value, args []js.Value) interface{} {
+	C.Send(new(chesspb.LeaveQueue))
+	LogToConsole("Stopped looking for an opponent.")
+	return nil
+}
+
+func acceptChallenge(this js.Value, args []js.Value) interface{} {
+	C.Send(&chesspb.AcceptChallenge{Game: uint32(args[0].Int())})
 	return nil
 }
 
+// drawLobby returns the HTML listing open challenges to accept, and games to watch.
+func drawLobby(list *chesspb.ChallengeList) string {
+	if len(list.Challenges) == 0 && len(list.Games) == 0 {
+		return "No open challenges."
+	}
+	var lobby string
+	for _, c := range list.Challenges {
+		lobby += fmt.Sprintf(`<button type="button" onclick="acceptchallenge(%d);">Accept</button> #%d %s<br>`, c.Game, c.Game, c.Describe())
+	}
+	for _, id := range list.Games {
+		lobby += fmt.Sprintf(`<button type="button" onclick="watchgame(%d);">Watch</button> #%d playing<br>`, id, id)
+	}
+	return lobby
+}
+
 func connect(this js.Value, args []js.Value) interface{} {
 	go func() { // blocks, so needs to be in a goroutine
 		Game = nil
-		MyTurn = false
 
 		window := js.Global()
 		document := window.Get("document")
@@ -221,7 +401,13 @@ func connect(this js.Value, args []js.Value) interface{} {
 			c.Close(websocket.StatusInternalError, "the sky is falling")
 			document.Call("getElementById", "connect").Set("disabled", false)
 			document.Call("getElementById", "join").Set("disabled", true)
+			document.Call("getElementById", "create").Set("disabled", true)
+			document.Call("getElementById", "watch").Set("disabled", true)
+			document.Call("getElementById", "challenge").Set("disabled", true)
+			document.Call("getElementById", "play").Set("disabled", true)
+			document.Call("getElementById", "cancelplay").Set("disabled", true)
 			document.Call("getElementById", "newgame").Set("disabled", true)
+			document.Call("getElementById", "lobby").Set("innerHTML", "")
 		}()
 		conn := websocket.NetConn(ctx, c, websocket.MessageBinary)
 
@@ -234,6 +420,12 @@ func connect(this js.Value, args []js.Value) interface{} {
 
 		LogToConsole("Connected!")
 		document.Call("getElementById", "join").Set("disabled", false)
+		document.Call("getElementById", "create").Set("disabled", false)
+		document.Call("getElementById", "watch").Set("disabled", false)
+		document.Call("getElementById", "challenge").Set("disabled", false)
+		document.Call("getElementById", "play").Set("disabled", false)
+		document.Call("getElementById", "cancelplay").Set("disabled", false)
+		C.Send(new(chesspb.ListGames))
 
 		for {
 			last := time.Now()
@@ -258,6 +450,9 @@ func connect(this js.Value, args []js.Value) interface{} {
 					}
 				} else {
 					Game.PromotePawn(int8(v.X), int8(v.Y), chess.Piece(v.To))
+					if v.WhiteTime != 0 || v.BlackTime != 0 {
+						setClocks(v.WhiteTime, v.BlackTime)
+					}
 					Promotion = nil
 					document.Call("getElementById", "blackpromotion").Set("hidden", true)
 					document.Call("getElementById", "whitepromotion").Set("hidden", true)
@@ -271,55 +466,84 @@ func connect(this js.Value, args []js.Value) interface{} {
 				LogToConsole("Opponent joined, game is ready to begin!")
 			case *chesspb.Player:
 				if v.One {
-					LogToConsole("Joined as player 1.")
+					LogToConsole(fmt.Sprintf("Joined game %d as player 1.", v.Game))
 				} else {
-					LogToConsole("Joined as player 2.")
+					LogToConsole(fmt.Sprintf("Joined game %d as player 2.", v.Game))
 				}
 			case *chesspb.Team:
 				if v.Black {
 					LogToConsole("You've been assigned to black.")
 					Black = true
-					MyTurn = false
 				} else {
 					LogToConsole("You've been assigned to white.")
 					Black = false
-					MyTurn = true
 				}
-				Game = chess.NewChessboard()
+				board := chess.NewChessboard()
+				if v.Fen != "" {
+					if start, err := chess.ParseFEN(v.Fen); err == nil {
+						board = start
+						if board.Chess960 {
+							LogToConsole("Playing Chess960.")
+						}
+					} else {
+						LogToConsole("Bad starting position from server: " + err.Error())
+					}
+				}
+				if board.Variant = v.Variant.Chess(); board.Variant != nil {
+					LogToConsole("Playing " + board.Variant.Name() + ".")
+				}
+				Game = chess.NewGame(board)
+				base := v.TimeControl.GetBase() * 1000
+				if v.WhiteTime != 0 || v.BlackTime != 0 { // watching a game in progress
+					setClocks(v.WhiteTime, v.BlackTime)
+				} else {
+					setClocks(base, base)
+				}
+				document.Call("getElementById", "clocks").Set("innerText", "")
+				if base != 0 {
+					LogToConsole("Playing " + v.TimeControl.Describe() + ".")
+				}
 				document.Call("getElementById", "chessboard").Set("innerHTML", drawBoard(Black))
 				Promotion = nil
 				document.Call("getElementById", "blackpromotion").Set("hidden", true)
 				document.Call("getElementById", "whitepromotion").Set("hidden", true)
 			case *chesspb.Move:
 				var check bool
+				from, to := v.From(), v.To()
 				switch chess.MoveType(v.MoveType) {
 				case chess.RegularMove:
-					animate([2]int8{int8(v.Fx), int8(v.Fy)}, [2]int8{int8(v.Tx), int8(v.Ty)})
-					check = Game.DoMove([2]int8{int8(v.Fx), int8(v.Fy)}, [2]int8{int8(v.Tx), int8(v.Ty)})
+					animate(from.Coords(), to.Coords())
+					check = Game.DoMove(from.Coords(), to.Coords())
 				case chess.EnPassant:
-					check = Game.DoEnPassant([2]int8{int8(v.Fx), int8(v.Fy)}, [2]int8{int8(v.Tx), int8(v.Ty)})
+					check = Game.DoEnPassant(from.Coords(), to.Coords())
 				case chess.CastleLeft:
-					check = Game.DoCastle([2]int8{int8(v.Fx), int8(v.Fy)}, true)
+					check = Game.DoCastle(from.Coords(), true)
 				case chess.CastleRight:
-					check = Game.DoCastle([2]int8{int8(v.Fx), int8(v.Fy)}, false)
+					check = Game.DoCastle(from.Coords(), false)
+				case chess.Drop:
+					check = Game.DoDrop(chess.Piece(v.Piece), to.Coords())
+				}
+				if v.WhiteTime != 0 || v.BlackTime != 0 {
+					setClocks(v.WhiteTime, v.BlackTime)
 				}
 				if check {
-					if MyTurn {
+					if Game.BlackMove != Black {
 						LogToConsole("Your opponent is in check.")
 					} else {
 						LogToConsole("You are in check.")
 					}
 				}
 				document.Call("getElementById", "chessboard").Set("innerHTML", drawBoard(Black))
-				MyTurn = !MyTurn
 			case *chesspb.GameComplete:
 				switch v.Result {
 				case chesspb.GameComplete_Stalemate:
 					LogToConsole("Game complete! Stalemate.")
 				case chesspb.GameComplete_WhiteWin:
-					LogToConsole("Game complete! White wins!")
+					LogToConsole("Game complete! White wins" + winReason(v.Reason) + "!")
 				case chesspb.GameComplete_BlackWin:
-					LogToConsole("Game complete! Black wins!")
+					LogToConsole("Game complete! Black wins" + winReason(v.Reason) + "!")
+				case chesspb.GameComplete_Draw:
+					LogToConsole("Game complete! Draw by " + v.Reason.Describe() + ".")
 				}
 				Game = nil
 				document.Call("getElementById", "newgame").Set("disabled", true)
@@ -327,6 +551,11 @@ func connect(this js.Value, args []js.Value) interface{} {
 				LogToConsole("Opponent left, need to rejoin.")
 				document.Call("getElementById", "newgame").Set("disabled", true)
 				Game = nil
+			case *chesspb.MatchFound:
+				challenge := &chesspb.Challenge{Variant: v.Variant, Chess960: v.Chess960, TimeControl: v.TimeControl}
+				LogToConsole(fmt.Sprintf("Found game %d against an opponent rated %d (%s).", v.Game, v.OpponentRating, challenge.Describe()))
+			case *chesspb.ChallengeList:
+				document.Call("getElementById", "lobby").Set("innerHTML", drawLobby(v))
 			case *chesspb.Error:
 				LogToConsole("Server error: " + v.Msg)
 			}
@@ -340,6 +569,12 @@ func setup() {
 	window := js.Global()
 	document := window.Get("document")
 
+	go func() {
+		for range time.Tick(100 * time.Millisecond) {
+			drawClocks()
+		}
+	}()
+
 	// callbacks
 	window.Set("connect", js.FuncOf(connect))
 	document.Call("getElementById", "connect").Call("setAttribute", "onClick", "connect();")
@@ -348,8 +583,19 @@ func setup() {
 	window.Set("joingame", js.FuncOf(joinGame))
 	window.Set("watchgame", js.FuncOf(watchGame))
 	document.Call("getElementById", "join").Call("setAttribute", "onClick", "joingame();")
+	window.Set("creategame", js.FuncOf(createGame))
+	document.Call("getElementById", "create").Call("setAttribute", "onClick", "creategame();")
+	document.Call("getElementById", "watch").Call("setAttribute", "onClick", "watchgame();")
+	window.Set("createchallenge", js.FuncOf(createChallenge))
+	window.Set("acceptchallenge", js.FuncOf(acceptChallenge))
+	document.Call("getElementById", "challenge").Call("setAttribute", "onClick", "createchallenge();")
+	window.Set("play", js.FuncOf(play))
+	document.Call("getElementById", "play").Call("setAttribute", "onClick", "play();")
+	window.Set("cancelplay", js.FuncOf(cancelPlay))
+	document.Call("getElementById", "cancelplay").Call("setAttribute", "onClick", "cancelplay();")
 
 	window.Set("selectpiece", js.FuncOf(selectPiece))
+	window.Set("selectdrop", js.FuncOf(selectDrop))
 	window.Set("selectPromotion", js.FuncOf(selectPromotion))
 }
 
@@ -0,0 +1,46 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+// Command diagram prints a diagram of a position, as text or SVG.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TheDiscordian/speedychess/chess"
+)
+
+func main() {
+	fen := flag.String("fen", chess.StartingFEN, "position to draw, in FEN")
+	svg := flag.Bool("svg", false, "write an SVG diagram rather than text")
+	ascii := flag.Bool("ascii", false, "draw pieces with letters rather than Unicode symbols")
+	flip := flag.Bool("flip", false, "draw the board from black's side")
+	coords := flag.Bool("coords", true, "label the files and ranks")
+	highlight := flag.String("highlight", "", "comma separated squares to highlight, ie: e2,e4")
+	flag.Parse()
+
+	cb, err := chess.ParseFEN(*fen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts := chess.RenderOptions{ASCII: *ascii, Flip: *flip, Coordinates: *coords}
+	if *highlight != "" {
+		for _, name := range strings.Split(*highlight, ",") {
+			s, err := chess.ParseSquare(strings.TrimSpace(name))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			opts.Highlight = append(opts.Highlight, s)
+		}
+	}
+
+	if *svg {
+		fmt.Print(cb.SVG(opts))
+	} else {
+		fmt.Print(cb.Render(opts))
+	}
+}
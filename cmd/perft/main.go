@@ -0,0 +1,67 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+// Command perft counts the positions reachable from a position, printing the count below each move.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/TheDiscordian/speedychess/chess"
+)
+
+func main() {
+	fen := flag.String("fen", chess.StartingFEN, "position to search, in FEN")
+	depth := flag.Int("depth", 4, "number of plies to search")
+	variant := flag.String("variant", "", "variant to play: kingofthehill, threecheck, atomic, crazyhouse (implied by a FEN with pockets) or antichess")
+	flag.Parse()
+
+	cb, err := chess.ParseFEN(*fen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch *variant {
+	case "":
+	case "kingofthehill":
+		cb.Variant = chess.KingOfTheHill{}
+	case "threecheck":
+		cb.Variant = chess.ThreeCheck{}
+	case "atomic":
+		cb.Variant = chess.Atomic{}
+	case "crazyhouse":
+		cb.Variant = chess.Crazyhouse{}
+	case "antichess":
+		cb.Variant = chess.Antichess{}
+	default:
+		fmt.Fprintln(os.Stderr, "Unknown variant "+*variant+".")
+		os.Exit(1)
+	}
+	if err := cb.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *depth < 1 {
+		fmt.Fprintln(os.Stderr, "Depth must be at least 1.")
+		os.Exit(1)
+	}
+
+	start := time.Now()
+	divide := cb.Divide(*depth)
+	elapsed := time.Since(start)
+
+	moves := make([]string, 0, len(divide))
+	for move := range divide {
+		moves = append(moves, move)
+	}
+	sort.Strings(moves)
+	var total uint64
+	for _, move := range moves {
+		fmt.Printf("%s: %d\n", move, divide[move])
+		total += divide[move]
+	}
+	fmt.Printf("\nMoves: %d\nNodes: %d\nTime: %v (%.0f nodes/s)\n", len(moves), total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+}
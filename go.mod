@@ -1,9 +1,11 @@
 module github.com/TheDiscordian/speedychess
 
-go 1.15
+go 1.18
 
 require (
 	github.com/golang/protobuf v1.4.2
 	google.golang.org/protobuf v1.25.0
 	nhooyr.io/websocket v1.8.6
 )
+
+require github.com/klauspost/compress v1.10.3 // indirect
@@ -0,0 +1,197 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+// Package pgn reads and writes games in Portable Game Notation.
+package pgn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheDiscordian/speedychess/chess"
+)
+
+// Game results, as used in the Result tag and at the end of the movetext.
+const (
+	WhiteWin   = "1-0"
+	BlackWin   = "0-1"
+	Draw       = "1/2-1/2"
+	Unfinished = "*"
+)
+
+// sevenTagRoster are the tags every exported game has, in order, along with their default values.
+var sevenTagRoster = [7][2]string{
+	{"Event", "?"},
+	{"Site", "?"},
+	{"Date", "????.??.??"},
+	{"Round", "?"},
+	{"White", "?"},
+	{"Black", "?"},
+	{"Result", Unfinished},
+}
+
+// variants maps values of the Variant tag, in lowercase, to the variants they name. Chess960 is standard chess played
+// from the position in the FEN tag.
+var variants = map[string]chess.Variant{
+	"standard":         nil,
+	"from position":    nil,
+	"chess960":         nil,
+	"king of the hill": chess.KingOfTheHill{},
+	"three-check":      chess.ThreeCheck{},
+	"atomic":           chess.Atomic{},
+	"crazyhouse":       chess.Crazyhouse{},
+	"antichess":        chess.Antichess{},
+}
+
+// Tag is a single PGN tag pair (ie: [Event "Casual game"]).
+type Tag struct {
+	Name, Value string
+}
+
+// Move is a single move in a game, along with any annotations that follow it.
+type Move struct {
+	SAN        string
+	NAGs       []int    // numeric annotation glyphs (ie: 1 for "!")
+	Comments   []string // comments following the move
+	Variations [][]Move // alternatives to this move, only kept if the Reader was asked to
+}
+
+// Game is a single game, its tags and its moves.
+type Game struct {
+	Tags    []Tag
+	Comment string // comment preceding the first move
+	Moves   []Move
+	Result  string // WhiteWin, BlackWin, Draw or Unfinished
+}
+
+// Tag returns the value of a tag, or "" if the game doesn't have it.
+func (g *Game) Tag(name string) string {
+	for _, tag := range g.Tags {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// SetTag sets the value of a tag, adding it if the game doesn't have it.
+func (g *Game) SetTag(name, value string) {
+	for i, tag := range g.Tags {
+		if tag.Name == name {
+			g.Tags[i].Value = value
+			return
+		}
+	}
+	g.Tags = append(g.Tags, Tag{Name: name, Value: value})
+}
+
+// Start returns the starting position of the game, taken from the FEN tag if present, and played by the rules of the
+// Variant tag if present.
+func (g *Game) Start() (*chess.Chessboard, error) {
+	cb := chess.NewChessboard()
+	if fen := g.Tag("FEN"); fen != "" {
+		var err error
+		if cb, err = chess.ParseFEN(fen); err != nil {
+			return nil, err
+		}
+	}
+	if name := g.Tag("Variant"); name != "" {
+		variant, ok := variants[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown variant %q", name)
+		}
+		if variant != nil {
+			cb.Variant = variant
+		}
+		if strings.EqualFold(name, "chess960") {
+			cb.Chess960 = true
+		}
+	}
+	return cb, nil
+}
+
+// Replay plays the game's moves from its starting position. It returns every position in the game, the first
+// being the starting position and the last being the position after the final move.
+func (g *Game) Replay() ([]*chess.Chessboard, error) {
+	cb, err := g.Start()
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]*chess.Chessboard, 0, len(g.Moves)+1)
+	positions = append(positions, cb)
+	for _, move := range g.Moves {
+		next := cb.Copy()
+		if err := next.DoSAN(move.SAN); err != nil {
+			return positions, err
+		}
+		positions = append(positions, next)
+		cb = next
+	}
+	return positions, nil
+}
+
+// FromGame returns a played game with its moves in SAN, and its Result and Termination tags set. The starting position
+// and variant are recorded in the FEN, SetUp and Variant tags if the game didn't start from the usual position.
+// Returns an error if a pawn is still waiting to be promoted.
+func FromGame(g *chess.Game) (*Game, error) {
+	pg := new(Game)
+	cb := g.Position(0)
+	if cb.Variant != nil {
+		pg.SetTag("Variant", cb.Variant.Name())
+	} else if cb.Chess960 {
+		pg.SetTag("Variant", "Chess960")
+	}
+	if fen := cb.FEN(); fen != chess.StartingFEN {
+		pg.SetTag("SetUp", "1")
+		pg.SetTag("FEN", fen)
+	}
+	for _, m := range g.Moves() {
+		piece := m.Promotion
+		if m.Type == chess.Drop {
+			piece = m.Dropped
+		} else if p := cb.Board[m.From[1]][m.From[0]]; (p == chess.WhitePawn || p == chess.BlackPawn) &&
+			(m.To[1] == 0 || m.To[1] == 7) && m.Promotion == 0 {
+			return nil, fmt.Errorf("move %d: pawn is waiting to be promoted", len(pg.Moves)+1)
+		}
+		san, err := cb.SAN(m.From, m.To, m.Type, piece)
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %v", len(pg.Moves)+1, err)
+		}
+		pg.Moves = append(pg.Moves, Move{SAN: san})
+		cb.Make(m)
+	}
+
+	switch g.Result {
+	case chess.WhiteWins:
+		pg.Result = WhiteWin
+	case chess.BlackWins:
+		pg.Result = BlackWin
+	case chess.Draw:
+		pg.Result = Draw
+	default:
+		pg.Result = Unfinished
+	}
+	pg.SetTag("Result", pg.Result)
+	switch g.Termination {
+	case chess.NotTerminated:
+		pg.SetTag("Termination", "unterminated")
+	case chess.Timeout:
+		pg.SetTag("Termination", "time forfeit")
+	default:
+		pg.SetTag("Termination", "normal")
+	}
+	return pg, nil
+}
+
+// validResult returns whether r is a valid game result.
+func validResult(r string) bool {
+	switch r {
+	case WhiteWin, BlackWin, Draw, Unfinished:
+		return true
+	}
+	return false
+}
+
+// escape escapes a tag value for writing.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
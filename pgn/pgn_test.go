@@ -0,0 +1,109 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package pgn
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/TheDiscordian/speedychess/chess"
+)
+
+func TestFromGame(t *testing.T) {
+	g := chess.NewGame(chess.NewChessboard())
+	for _, san := range []string{"f3", "e5", "g4", "Qh4#"} {
+		if err := g.DoSAN(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pg, err := FromGame(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pg.Result != BlackWin || pg.Tag("Result") != BlackWin || pg.Tag("Termination") != "normal" ||
+		pg.Tag("FEN") != "" || pg.Tag("Variant") != "" {
+		t.Errorf("Tags, Result = %v, %s", pg.Tags, pg.Result)
+	}
+	if s := pg.String(); !strings.Contains(s, "\n1. f3 e5 2. g4 Qh4# 0-1\n") {
+		t.Errorf("String() = %q", s)
+	}
+
+	// a variant from a set up position, lost on time
+	cb, err := chess.ParseFEN("4k3/8/8/8/8/8/8/4K2R w K - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb.Variant = chess.KingOfTheHill{}
+	g = chess.NewGame(cb)
+	for _, san := range []string{"O-O", "Kd7"} {
+		if err := g.DoSAN(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.Timeout(false)
+	if pg, err = FromGame(g); err != nil {
+		t.Fatal(err)
+	}
+	if pg.Result != BlackWin || pg.Tag("Termination") != "time forfeit" || pg.Tag("Variant") != "King of the Hill" ||
+		pg.Tag("SetUp") != "1" || pg.Tag("FEN") != "4k3/8/8/8/8/8/8/4K2R w K - 0 1" {
+		t.Errorf("Tags, Result = %v, %s", pg.Tags, pg.Result)
+	}
+	positions, err := pg.Replay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := positions[len(positions)-1]
+	if _, ok := last.Variant.(chess.KingOfTheHill); !ok || last.FEN() != g.FEN() {
+		t.Errorf("replayed %s, %v, want %s", last.FEN(), last.Variant, g.FEN())
+	}
+
+	// a game waiting for a promotion can't be written
+	cb, _ = chess.ParseFEN("7k/P7/6K1/8/8/8/8/8 w - - 0 1")
+	g = chess.NewGame(cb)
+	g.DoMove([2]int8{0, 1}, [2]int8{0, 0})
+	if _, err := FromGame(g); err == nil {
+		t.Error("FromGame() succeeded with a promotion pending")
+	}
+	g.PromotePawn(0, 0, chess.WhiteQueen)
+	if pg, err = FromGame(g); err != nil || !reflect.DeepEqual(pg.Moves, []Move{{SAN: "a8=Q#"}}) {
+		t.Errorf("FromGame() = %v, %v, want a8=Q#", pg, err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	for _, test := range []struct {
+		variant, fen string
+		want         chess.Variant
+		chess960     bool
+	}{
+		{"", "", nil, false},
+		{"Standard", "", nil, false},
+		{"Three-check", "", chess.ThreeCheck{}, false},
+		{"antichess", "", chess.Antichess{}, false},
+		{"Crazyhouse", chess.StartingFEN, chess.Crazyhouse{}, false},
+		{"Chess960", chess.StartingFEN, nil, true},
+	} {
+		g := &Game{}
+		if test.variant != "" {
+			g.SetTag("Variant", test.variant)
+		}
+		if test.fen != "" {
+			g.SetTag("FEN", test.fen)
+		}
+		cb, err := g.Start()
+		if err != nil {
+			t.Errorf("%s: Start(): %v", test.variant, err)
+			continue
+		}
+		if cb.Variant != test.want || cb.Chess960 != test.chess960 {
+			t.Errorf("%s: Start() = %v, Chess960 %v, want %v, %v", test.variant, cb.Variant, cb.Chess960, test.want,
+				test.chess960)
+		}
+	}
+
+	g := &Game{Tags: []Tag{{"Variant", "Bughouse"}}}
+	if _, err := g.Start(); err == nil {
+		t.Error("Start() succeeded with an unknown variant")
+	}
+}
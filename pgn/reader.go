@@ -0,0 +1,355 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package pgn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// suffixNAGs maps move suffix annotations to their numeric annotation glyphs.
+var suffixNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+// Reader reads games from a PGN file, which may contain any number of games.
+type Reader struct {
+	r         *bufio.Reader
+	line      int
+	lineStart bool // whether the next byte starts a line
+
+	// KeepVariations keeps variations (ie: "(1... e6)") as Move.Variations, rather than skipping them.
+	KeepVariations bool
+}
+
+// NewReader returns a Reader reading PGN from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), line: 1, lineStart: true}
+}
+
+// ReadAll reads every game from r.
+func ReadAll(r io.Reader) ([]*Game, error) {
+	var games []*Game
+	reader := NewReader(r)
+	for {
+		g, err := reader.Read()
+		if err == io.EOF {
+			return games, nil
+		}
+		if err != nil {
+			return games, err
+		}
+		games = append(games, g)
+	}
+}
+
+func (r *Reader) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("PGN line %d: %s", r.line, fmt.Sprintf(format, a...))
+}
+
+// skipEscapes skips escape lines, which start with % and are ignored, if at the start of a line.
+func (r *Reader) skipEscapes() error {
+	for r.lineStart {
+		if p, _ := r.r.Peek(1); len(p) == 0 || p[0] != '%' {
+			break
+		}
+		if _, err := r.r.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return err
+		}
+		r.line++
+	}
+	r.lineStart = false
+	return nil
+}
+
+// next returns the next byte, or 0 at the end of input.
+func (r *Reader) next() (byte, error) {
+	if err := r.skipEscapes(); err != nil {
+		return 0, err
+	}
+	b, err := r.r.ReadByte()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if b == '\n' {
+		r.line++
+		r.lineStart = true
+	}
+	return b, err
+}
+
+// peek returns the next byte without consuming it, or 0 at the end of input.
+func (r *Reader) peek() byte {
+	if err := r.skipEscapes(); err != nil {
+		return 0
+	}
+	p, _ := r.r.Peek(1)
+	if len(p) == 0 {
+		return 0
+	}
+	return p[0]
+}
+
+// skipSpace consumes whitespace.
+func (r *Reader) skipSpace() error {
+	for {
+		switch r.peek() {
+		case ' ', '\t', '\r', '\n':
+			if _, err := r.next(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// readUntil reads up to and including delim, returning what came before it.
+func (r *Reader) readUntil(delim byte) (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := r.next()
+		if err != nil {
+			return "", err
+		}
+		if b == 0 {
+			return sb.String(), r.errorf("unexpected end of input, expected %q", delim)
+		}
+		if b == delim {
+			return sb.String(), nil
+		}
+		sb.WriteByte(b)
+	}
+}
+
+// readTag reads a tag pair, the opening '[' already having been consumed.
+func (r *Reader) readTag() (tag Tag, err error) {
+	if err = r.skipSpace(); err != nil {
+		return
+	}
+	var name strings.Builder
+	for b := r.peek(); b != 0 && b != ' ' && b != '\t' && b != '"' && b != ']'; b = r.peek() {
+		r.next()
+		name.WriteByte(b)
+	}
+	tag.Name = name.String()
+	if tag.Name == "" {
+		return tag, r.errorf("tag is missing its name")
+	}
+	if err = r.skipSpace(); err != nil {
+		return
+	}
+	if b, _ := r.next(); b != '"' {
+		return tag, r.errorf("tag %s is missing its value", tag.Name)
+	}
+	var value strings.Builder
+	for {
+		b, err := r.next()
+		if err != nil {
+			return tag, err
+		}
+		switch b {
+		case 0, '\n':
+			return tag, r.errorf("tag %s has an unterminated value", tag.Name)
+		case '\\':
+			if b, err = r.next(); err != nil {
+				return tag, err
+			}
+		case '"':
+			tag.Value = value.String()
+			if err = r.skipSpace(); err != nil {
+				return tag, err
+			}
+			if b, _ := r.next(); b != ']' {
+				return tag, r.errorf("tag %s is missing its closing ']'", tag.Name)
+			}
+			return tag, nil
+		}
+		value.WriteByte(b)
+	}
+}
+
+// readSymbol reads a move, move number or result.
+func (r *Reader) readSymbol() (string, error) {
+	var sb strings.Builder
+	for {
+		switch b := r.peek(); b {
+		case 0, ' ', '\t', '\r', '\n', '{', '}', '(', ')', ';', '$', '[', ']':
+			return sb.String(), nil
+		default:
+			if _, err := r.next(); err != nil {
+				return "", err
+			}
+			sb.WriteByte(b)
+		}
+	}
+}
+
+// readMoves reads moves until the end of a variation (if depth > 0) or the end of the game, returning the moves
+// and the game result if one was read. A comment preceding the first move is returned as comment.
+func (r *Reader) readMoves(depth int) (moves []Move, comment, result string, err error) {
+	addComment := func(c string) {
+		c = strings.TrimSpace(c)
+		if len(moves) == 0 {
+			if comment != "" {
+				comment += " "
+			}
+			comment += c
+		} else {
+			last := &moves[len(moves)-1]
+			last.Comments = append(last.Comments, c)
+		}
+	}
+	for {
+		if err = r.skipSpace(); err != nil {
+			return
+		}
+		switch b := r.peek(); b {
+		case 0:
+			if depth > 0 {
+				err = r.errorf("unterminated variation")
+			}
+			return
+		case '[':
+			if depth > 0 {
+				err = r.errorf("unterminated variation")
+			}
+			return // next game, this one had no result
+		case '{':
+			r.next()
+			var c string
+			if c, err = r.readUntil('}'); err != nil {
+				return
+			}
+			addComment(c)
+		case ';':
+			r.next()
+			var c strings.Builder
+			for b := r.peek(); b != 0 && b != '\n'; b = r.peek() {
+				r.next()
+				c.WriteByte(b)
+			}
+			addComment(c.String())
+		case '$':
+			r.next()
+			var s string
+			if s, err = r.readSymbol(); err != nil {
+				return
+			}
+			nag, convErr := strconv.Atoi(s)
+			if convErr != nil || len(moves) == 0 {
+				err = r.errorf("invalid annotation $%s", s)
+				return
+			}
+			moves[len(moves)-1].NAGs = append(moves[len(moves)-1].NAGs, nag)
+		case '(':
+			r.next()
+			if len(moves) == 0 {
+				err = r.errorf("variation has no move to replace")
+				return
+			}
+			var variation []Move
+			if variation, _, _, err = r.readMoves(depth + 1); err != nil {
+				return
+			}
+			if r.KeepVariations {
+				last := &moves[len(moves)-1]
+				last.Variations = append(last.Variations, variation)
+			}
+		case ')':
+			r.next()
+			if depth == 0 {
+				err = r.errorf("unexpected ')'")
+			}
+			return
+		default:
+			var s string
+			if s, err = r.readSymbol(); err != nil {
+				return
+			}
+			if s == "" {
+				r.next()
+				err = r.errorf("unexpected %q", b)
+				return
+			}
+			if validResult(s) {
+				if depth > 0 {
+					continue // some files end variations with a result
+				}
+				result = s
+				return
+			}
+			// strip move numbers (ie: "12." or "12...") which may be attached to the move, leaving castling with
+			// zeros (ie: "0-0") alone
+			digits := len(s) - len(strings.TrimLeft(s, "0123456789"))
+			if digits == len(s) {
+				continue // a move number followed by whitespace
+			}
+			if s[digits] == '.' {
+				s = strings.TrimLeft(s[digits:], ".")
+				if s == "" {
+					continue
+				}
+			} else if digits > 0 && !strings.HasPrefix(s, "0-0") {
+				err = r.errorf("malformed move %q", s)
+				return
+			}
+			// suffix annotations
+			san := strings.TrimRight(s, "!?")
+			move := Move{SAN: san}
+			if suffix := s[len(san):]; suffix != "" {
+				nag, ok := suffixNAGs[suffix]
+				if !ok {
+					err = r.errorf("invalid annotation %q", suffix)
+					return
+				}
+				move.NAGs = append(move.NAGs, nag)
+			}
+			if san == "" {
+				err = r.errorf("annotation %q has no move", s)
+				return
+			}
+			moves = append(moves, move)
+		}
+	}
+}
+
+// Read reads the next game, returning io.EOF when there are no more games.
+func (r *Reader) Read() (*Game, error) {
+	g := new(Game)
+	for {
+		if err := r.skipSpace(); err != nil {
+			return nil, err
+		}
+		if r.peek() != '[' {
+			break
+		}
+		r.next()
+		tag, err := r.readTag()
+		if err != nil {
+			return nil, err
+		}
+		g.SetTag(tag.Name, tag.Value)
+	}
+
+	moves, comment, result, err := r.readMoves(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Tags) == 0 && len(moves) == 0 && comment == "" && result == "" {
+		return nil, io.EOF
+	}
+	g.Moves, g.Comment = moves, comment
+	if result == "" {
+		result = g.Tag("Result")
+	}
+	if !validResult(result) {
+		result = Unfinished
+	}
+	g.Result = result
+	return g, nil
+}
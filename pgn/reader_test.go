@@ -0,0 +1,122 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package pgn
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPGN = `% exported by a tool which writes escape lines
+[Event "Casual game"]
+[Site "Somewhere \"quoted\""]
+[Result "1-0"]
+
+{Opening comment} 1. e4 $1 e5 {Black mirrors} 2. Nf3!? (2. Nc3 Nf6 (2... Nc6) 3. f4) 2... Nc6
+% an escape line in the middle of the movetext
+3. Bc4 Nf6 4. 0-0 Bc5 5. d3 O-O ; a rest of line comment
+6. Bg5 1-0
+
+[Event "Second game"]
+[FEN "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 12"]
+
+12... O-O-O 13. 0-0 *
+`
+
+func TestRead(t *testing.T) {
+	r := NewReader(strings.NewReader(testPGN))
+	r.KeepVariations = true
+	g, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Tag("Event") != "Casual game" || g.Tag("Site") != `Somewhere "quoted"` || g.Result != WhiteWin {
+		t.Errorf("Tags, Result = %v, %s", g.Tags, g.Result)
+	}
+	if g.Comment != "Opening comment" {
+		t.Errorf("Comment = %q, want %q", g.Comment, "Opening comment")
+	}
+	var sans []string
+	for _, m := range g.Moves {
+		sans = append(sans, m.SAN)
+	}
+	want := []string{"e4", "e5", "Nf3", "Nc6", "Bc4", "Nf6", "0-0", "Bc5", "d3", "O-O", "Bg5"}
+	if !reflect.DeepEqual(sans, want) {
+		t.Fatalf("Moves = %v, want %v", sans, want)
+	}
+	if !reflect.DeepEqual(g.Moves[0].NAGs, []int{1}) || !reflect.DeepEqual(g.Moves[2].NAGs, []int{5}) {
+		t.Errorf("NAGs = %v, %v, want [1], [5]", g.Moves[0].NAGs, g.Moves[2].NAGs)
+	}
+	if !reflect.DeepEqual(g.Moves[1].Comments, []string{"Black mirrors"}) ||
+		!reflect.DeepEqual(g.Moves[9].Comments, []string{"a rest of line comment"}) {
+		t.Errorf("Comments = %q, %q", g.Moves[1].Comments, g.Moves[9].Comments)
+	}
+	if vs := g.Moves[2].Variations; len(vs) != 1 || len(vs[0]) != 3 || vs[0][0].SAN != "Nc3" ||
+		len(vs[0][1].Variations) != 1 || vs[0][1].Variations[0][0].SAN != "Nc6" {
+		t.Errorf("Variations = %v", vs)
+	}
+	positions, err := g.Replay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != len(g.Moves)+1 {
+		t.Errorf("Replay() returned %d positions, want %d", len(positions), len(g.Moves)+1)
+	}
+
+	g, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Result != Unfinished || len(g.Moves) != 2 || g.Moves[1].SAN != "0-0" {
+		t.Errorf("second game: Moves, Result = %v, %s", g.Moves, g.Result)
+	}
+	if _, err := g.Replay(); err != nil {
+		t.Error(err)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Read() after the last game = %v, want EOF", err)
+	}
+}
+
+func TestReadResultTag(t *testing.T) {
+	games, err := ReadAll(strings.NewReader("[Result \"1/2-1/2\"]\n\n1. e4 e5\n\n[Result \"?\"]\n\n1. d4\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 || games[0].Result != Draw || games[1].Result != Unfinished {
+		t.Errorf("ReadAll() = %v, want a draw then an unfinished game", games)
+	}
+}
+
+func TestReadSkipsVariations(t *testing.T) {
+	games, err := ReadAll(strings.NewReader("1. e4 (1. d4 d5) e5 *"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || len(games[0].Moves) != 2 || games[0].Moves[0].Variations != nil {
+		t.Errorf("ReadAll() = %v, want two moves without variations", games[0].Moves)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, s := range []string{
+		`[Event "unterminated]`,
+		`[ "no name"]`,
+		`[Event]`,
+		"1. e4 (1. d4",
+		"1. e4 )",
+		"1. e4 $x",
+		"$1 1. e4",
+		"1. e4 {unterminated",
+		"1. (1. d4) e4",
+		"1. e4?!? e5",
+		"1. !! e5",
+		"12e4",
+	} {
+		if _, err := ReadAll(strings.NewReader(s)); err == nil {
+			t.Errorf("ReadAll(%q) succeeded, want an error", s)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package pgn
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+const lineLength = 79 // maximum length of a line of movetext
+
+// movetext builds movetext, wrapping it into lines.
+type movetext struct {
+	sb   strings.Builder
+	line int // length of the current line
+}
+
+func (mt *movetext) write(token string) {
+	if mt.line > 0 && mt.line+1+len(token) > lineLength {
+		mt.sb.WriteByte('\n')
+		mt.line = 0
+	} else if mt.line > 0 {
+		mt.sb.WriteByte(' ')
+		mt.line++
+	}
+	mt.sb.WriteString(token)
+	mt.line += len(token)
+}
+
+// commentTokens splits a comment into words, so it can be wrapped.
+func commentTokens(c string) []string {
+	words := strings.Fields(strings.ReplaceAll(c, "}", ""))
+	if len(words) == 0 {
+		return []string{"{}"}
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	return words
+}
+
+// moveTokens returns the tokens of moves starting at ply (0 being white's first move).
+func moveTokens(moves []Move, ply int) (tokens []string) {
+	needNumber := true // black moves need a number after a comment or variation
+	for _, move := range moves {
+		if ply%2 == 0 {
+			tokens = append(tokens, strconv.Itoa(ply/2+1)+".")
+		} else if needNumber {
+			tokens = append(tokens, strconv.Itoa(ply/2+1)+"...")
+		}
+		tokens = append(tokens, move.SAN)
+		needNumber = false
+		for _, nag := range move.NAGs {
+			tokens = append(tokens, "$"+strconv.Itoa(nag))
+		}
+		for _, c := range move.Comments {
+			tokens = append(tokens, commentTokens(c)...)
+			needNumber = true
+		}
+		for _, variation := range move.Variations {
+			vtokens := moveTokens(variation, ply)
+			if len(vtokens) == 0 {
+				continue
+			}
+			vtokens[0] = "(" + vtokens[0]
+			vtokens[len(vtokens)-1] += ")"
+			tokens = append(tokens, vtokens...)
+			needNumber = true
+		}
+		ply++
+	}
+	return
+}
+
+// result returns the game's result, falling back to its Result tag if Result isn't set.
+func (g *Game) result() string {
+	if validResult(g.Result) {
+		return g.Result
+	}
+	if r := g.Tag("Result"); validResult(r) {
+		return r
+	}
+	return Unfinished
+}
+
+// String returns the game in PGN export format. The Seven Tag Roster always comes first, filled with defaults
+// where missing, and the Result tag always matches the result ending the movetext.
+func (g *Game) String() string {
+	var sb strings.Builder
+	result := g.result()
+	for _, tag := range sevenTagRoster {
+		value := g.Tag(tag[0])
+		if tag[0] == "Result" {
+			value = result
+		} else if value == "" {
+			value = tag[1]
+		}
+		sb.WriteString("[" + tag[0] + " \"" + escape(value) + "\"]\n")
+	}
+	for _, tag := range g.Tags {
+		if isRosterTag(tag.Name) {
+			continue
+		}
+		sb.WriteString("[" + tag.Name + " \"" + escape(tag.Value) + "\"]\n")
+	}
+	sb.WriteByte('\n')
+
+	mt := new(movetext)
+	if g.Comment != "" {
+		for _, token := range commentTokens(g.Comment) {
+			mt.write(token)
+		}
+	}
+	ply := 0
+	if cb, err := g.Start(); err == nil {
+		ply = (cb.FullMoveNumber - 1) * 2
+		if cb.BlackMove {
+			ply++
+		}
+	}
+	for _, token := range moveTokens(g.Moves, ply) {
+		mt.write(token)
+	}
+	mt.write(result)
+	sb.WriteString(mt.sb.String())
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// isRosterTag returns whether name is part of the Seven Tag Roster.
+func isRosterTag(name string) bool {
+	for _, tag := range sevenTagRoster {
+		if tag[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes games to w in PGN export format, separated by blank lines.
+func Write(w io.Writer, games ...*Game) error {
+	for i, g := range games {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, g.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
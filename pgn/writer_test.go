@@ -0,0 +1,78 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package pgn
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGameString(t *testing.T) {
+	g := &Game{
+		Tags:   []Tag{{"White", "Anna"}, {"Annotator", `A "quote"`}},
+		Moves:  []Move{{SAN: "e4", NAGs: []int{1}}, {SAN: "e5", Comments: []string{"solid"}}, {SAN: "O-O"}},
+		Result: Draw,
+	}
+	want := `[Event "?"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "Anna"]
+[Black "?"]
+[Result "1/2-1/2"]
+[Annotator "A \"quote\""]
+
+1. e4 $1 e5 {solid} 2. O-O 1/2-1/2
+`
+	if s := g.String(); s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+
+	// the Result tag is used when Result isn't set, and always matches the movetext
+	g = &Game{Tags: []Tag{{"Result", BlackWin}}}
+	if s := g.String(); !strings.Contains(s, `[Result "0-1"]`) || !strings.HasSuffix(s, "\n0-1\n") {
+		t.Errorf("String() = %q, want the result 0-1", s)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := NewReader(strings.NewReader(testPGN))
+	r.KeepVariations = true
+	var games []*Game
+	for {
+		g, err := r.Read()
+		if err != nil {
+			break
+		}
+		games = append(games, g)
+	}
+	if len(games) != 2 {
+		t.Fatalf("read %d games, want 2", len(games))
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, games...); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); !strings.Contains(s, "4. 0-0 Bc5") || !strings.Contains(s, "12... O-O-O 13. 0-0 *") {
+		t.Errorf("Write() lost castling or move numbers:\n%s", s)
+	}
+	r = NewReader(&buf)
+	r.KeepVariations = true
+	for i, want := range games {
+		g, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range want.Tags {
+			if g.Tag(tag.Name) != tag.Value {
+				t.Errorf("game %d: tag %s = %q, want %q", i, tag.Name, g.Tag(tag.Name), tag.Value)
+			}
+		}
+		if g.Comment != want.Comment || g.Result != want.Result || !reflect.DeepEqual(g.Moves, want.Moves) {
+			t.Errorf("game %d was read back as\n%v\nwant\n%v", i, g, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package main
+
+import (
+	"time"
+
+	"github.com/TheDiscordian/speedychess/chesspb"
+)
+
+// Clock is a chess clock for a game, which isn't safe to use concurrently. White's clock starts running with the
+// game, and each move stops the mover's clock and starts their opponent's.
+type Clock struct {
+	kind    chesspb.TimeControl_Kind
+	delay   time.Duration    // increment or delay, depending on kind
+	left    [2]time.Duration // time left for white then black, as of started
+	turn    Color            // whose clock is running, None once stopped
+	started time.Time        // when the running clock was started
+}
+
+// NewClock returns a clock for the time control started now, or nil if there's no clock.
+func NewClock(tc *chesspb.TimeControl, now time.Time) *Clock {
+	if tc.GetBase() == 0 {
+		return nil
+	}
+	base := time.Duration(tc.Base) * time.Second
+	return &Clock{
+		kind:    tc.Kind,
+		delay:   time.Duration(tc.Increment) * time.Second,
+		left:    [2]time.Duration{base, base},
+		turn:    White,
+		started: now,
+	}
+}
+
+// used returns how much time the running clock has counted down since it started, which the delay is excluded from.
+func (c *Clock) used(now time.Time) time.Duration {
+	used := now.Sub(c.started)
+	if c.kind == chesspb.TimeControl_SimpleDelay {
+		used -= c.delay
+		if used < 0 {
+			return 0
+		}
+	}
+	return used
+}
+
+// Remaining returns the colour's time left as of now, which may be negative once its flag has fallen.
+func (c *Clock) Remaining(color Color, now time.Time) time.Duration {
+	if color != c.turn {
+		return c.left[color]
+	}
+	return c.left[color] - c.used(now)
+}
+
+// Flag returns the colour whose time has run out as of now, or None.
+func (c *Clock) Flag(now time.Time) Color {
+	if c.turn != None && c.Remaining(c.turn, now) <= 0 {
+		return c.turn
+	}
+	return None
+}
+
+// Until returns how long the running clock has until its flag falls, as of now.
+func (c *Clock) Until(now time.Time) time.Duration {
+	if c.turn == None {
+		return 0
+	}
+	until := c.Remaining(c.turn, now)
+	if c.kind == chesspb.TimeControl_SimpleDelay && now.Sub(c.started) < c.delay {
+		until += c.delay - now.Sub(c.started)
+	}
+	return until
+}
+
+// Press ends the turn of the colour whose clock is running at now, adding any increment, and starts their opponent's.
+func (c *Clock) Press(now time.Time) {
+	if c.turn == None {
+		return
+	}
+	used := c.used(now)
+	c.left[c.turn] -= used
+	switch c.kind {
+	case chesspb.TimeControl_Fischer:
+		c.left[c.turn] += c.delay
+	case chesspb.TimeControl_Bronstein:
+		if used < c.delay {
+			c.left[c.turn] += used
+		} else {
+			c.left[c.turn] += c.delay
+		}
+	}
+	c.turn, c.started = 1-c.turn, now
+}
+
+// Stop stops the clock at now.
+func (c *Clock) Stop(now time.Time) {
+	if c.turn != None {
+		c.left[c.turn] = c.Remaining(c.turn, now)
+		c.turn = None
+	}
+}
+
+// Millis returns the time left for white and black in milliseconds as of now, 0 if it has run out.
+func (c *Clock) Millis(now time.Time) (white, black uint32) {
+	ms := func(color Color) uint32 {
+		if left := c.Remaining(color, now); left > 0 {
+			return uint32(left / time.Millisecond)
+		}
+		return 0
+	}
+	return ms(White), ms(Black)
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheDiscordian/speedychess/chess"
+	"github.com/TheDiscordian/speedychess/chesspb"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Now()
+	at := func(seconds float64) time.Time {
+		return start.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	for _, test := range []struct {
+		kind          chesspb.TimeControl_Kind
+		first, second time.Duration // white's time after a 2 second move, then a 5 second one
+		black         time.Duration // black's time a second into their turn
+	}{
+		{chesspb.TimeControl_Fischer, 61 * time.Second, 59 * time.Second, 59 * time.Second},
+		{chesspb.TimeControl_Bronstein, 60 * time.Second, 58 * time.Second, 59 * time.Second},
+		{chesspb.TimeControl_SimpleDelay, 60 * time.Second, 58 * time.Second, 60 * time.Second},
+	} {
+		c := NewClock(&chesspb.TimeControl{Base: 60, Increment: 3, Kind: test.kind}, start)
+		c.Press(at(2))
+		if got := c.Remaining(White, at(3)); got != test.first {
+			t.Errorf("%v: white has %v after their first move, want %v", test.kind, got, test.first)
+		}
+		if got := c.Remaining(Black, at(3)); got != test.black {
+			t.Errorf("%v: black has %v a second into their turn, want %v", test.kind, got, test.black)
+		}
+		c.Press(at(3))
+		c.Press(at(8))
+		if got := c.Remaining(White, at(8)); got != test.second {
+			t.Errorf("%v: white has %v after their second move, want %v", test.kind, got, test.second)
+		}
+	}
+
+	if NewClock(nil, start) != nil || NewClock(&chesspb.TimeControl{Increment: 5}, start) != nil {
+		t.Errorf("NewClock() made a clock without any time")
+	}
+	c := NewClock(&chesspb.TimeControl{Base: 10, Increment: 2, Kind: chesspb.TimeControl_SimpleDelay}, start)
+	if until := c.Until(at(1)); until != 11*time.Second {
+		t.Errorf("Until() = %v, want the delay left plus the base", until)
+	}
+	if c.Flag(at(11.9)) != None || c.Flag(at(12)) != White {
+		t.Errorf("white's flag should fall 12 seconds in")
+	}
+	if white, black := c.Millis(at(13)); white != 0 || black != 10000 {
+		t.Errorf("Millis() = %d, %d", white, black)
+	}
+}
+
+func TestRoomTimeout(t *testing.T) {
+	reg := NewRegistry()
+	a, b := newClient(), newClient()
+	room := reg.Create(&chesspb.Challenge{TimeControl: &chesspb.TimeControl{Base: 60, Increment: 1}})
+	colorA, _ := room.Pair(a, b, 1500, 1500)
+	white := a
+	if colorA == Black {
+		white = b
+	}
+	for _, c := range []*chesspb.Client{a, b} {
+		msgs := received(t, c)
+		if team, ok := msgs[len(msgs)-1].(*chesspb.Team); !ok || team.TimeControl.GetBase() != 60 {
+			t.Fatalf("game didn't start with a clock, received %v", msgs)
+		}
+	}
+
+	room.Move(white, White, chesspb.NewMove(chess.SquareAt(4, 6), chess.SquareAt(4, 4), chess.RegularMove))
+	msgs := received(t, a)
+	move, ok := msgs[0].(*chesspb.Move)
+	if !ok || move.WhiteTime < 60000 || move.WhiteTime > 61000 || move.BlackTime == 0 || move.BlackTime > 60000 {
+		t.Fatalf("move was sent with the wrong times: %v", msgs)
+	}
+
+	// black's flag falls
+	room.mu.Lock()
+	room.clock.started = room.clock.started.Add(-time.Minute)
+	room.mu.Unlock()
+	room.checkFlag()
+	msgs = received(t, a)
+	if len(msgs) == 0 {
+		t.Fatal("game didn't end when black ran out of time")
+	}
+	complete, ok := msgs[0].(*chesspb.GameComplete)
+	if !ok || complete.Result != chesspb.GameComplete_WhiteWin || complete.Reason != chesspb.GameComplete_Timeout {
+		t.Errorf("game ended with %v", msgs[0])
+	}
+	if !room.Closed() {
+		t.Errorf("room wasn't closed after the timeout")
+	}
+}
@@ -10,10 +10,8 @@ import (
 	"net"
 	"net/http"
 	"runtime/debug"
-	"sync/atomic"
 	"time"
 
-	"github.com/TheDiscordian/speedychess/chess"
 	"github.com/TheDiscordian/speedychess/chesspb"
 	"google.golang.org/protobuf/proto"
 	"nhooyr.io/websocket"
@@ -36,28 +34,17 @@ const (
 )
 
 var (
-	players     *int32 // players represents how many actual players are joined (0, 1, or 2)
-	GameRunning bool
-	Game        *chess.Chessboard
-
-	BlackClient    *chesspb.Client
-	WhiteClient    *chesspb.Client
-	BlackMove      bool  // if true, it's black's move
-	NeedPromotion  Color // represents a colour that needs to promote a pawn for the game to continue
-	ObserverClient *chesspb.Client
+	Rooms   = NewRegistry()        // every game on the server
+	Matches = NewMatchmaker(Rooms) // players waiting to be matched
 )
 
-// TODO keep observers alive
+// keepAlive pings everyone in every room, and removes rooms which are closed and empty.
 func keepAlive() {
 	for {
-		if WhiteClient != nil {
-			WhiteClient.Send(new(chesspb.Ping))
-		}
-		if BlackClient != nil {
-			BlackClient.Send(new(chesspb.Ping))
-		}
-		if ObserverClient != nil {
-			ObserverClient.Send(new(chesspb.Ping))
+		for _, r := range Rooms.Rooms() {
+			if !r.Ping() {
+				Rooms.Remove(r.ID)
+			}
 		}
 		time.Sleep(PING_INTERVAL)
 	}
@@ -65,43 +52,55 @@ func keepAlive() {
 
 func handleConnection(conn net.Conn) {
 	var (
-		msg   proto.Message
-		color Color
+		msg    proto.Message
+		room   *Room   // the room joined, or nil
+		color  Color   = None
+		queued *seeker // the client's place in the matchmaking queue, or nil
 	)
 	reader := bufio.NewReader(conn) //reader for the connection
 
 	c := &chesspb.Client{W: make(chan []byte, 5)}
 	go c.Writer(conn)
 
-	playern := -1 // 0 or 1 are players, -1 is not assigned, anything above 1 is a spectator (and it doesn't matter)
+	// unqueue leaves the matchmaking queue, taking up the game if a match was already found
+	unqueue := func() {
+		if queued != nil && !Matches.Dequeue(queued) {
+			m := <-queued.matched
+			room, color = m.room, m.color
+		}
+		queued = nil
+	}
 
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Println("Client", conn.RemoteAddr().String(), "crashed goroutine:", r, "\n"+string(debug.Stack()))
 		}
 
-		if playern == 0 || playern == 1 {
-			atomic.StoreInt32(players, 0)
-			GameRunning = false
-			BlackMove = false
-			if color == Black && WhiteClient != nil {
-				WhiteClient.Send(new(chesspb.OpponentLeft))
-			} else if color == White && BlackClient != nil {
-				BlackClient.Send(new(chesspb.OpponentLeft))
-			}
-			WhiteClient = nil
-			BlackClient = nil
-			NeedPromotion = None
-		}
-		if playern == 3 {
-			ObserverClient = nil
+		unqueue()
+		if room != nil {
+			Rooms.Leave(c, room, color)
 		}
+		Rooms.Unsubscribe(c)
 
 		//cleanup
 		conn.Close()
 		c.SendBytes(nil)
 	}()
 
+	// seat readies c to play or watch another game, and returns false if it's playing one already
+	seat := func() bool {
+		unqueue()
+		if color != None {
+			c.Send(&chesspb.Error{Msg: "You're already a player."})
+			return false
+		}
+		if room != nil {
+			Rooms.Leave(c, room, None)
+			room = nil
+		}
+		return true
+	}
+
 	reqs := 0
 	reqs_last := time.Now()
 
@@ -114,8 +113,9 @@ func handleConnection(conn net.Conn) {
 			fmt.Println("Too many requests from", conn.RemoteAddr().String())
 			break
 		}
-		if playern != 3 && atomic.LoadInt32(players) == 0 {
-			playern = -1
+		// players of a finished game need to join another, spectators stay until they do
+		if color != None && room.Closed() {
+			room, color = nil, None
 		}
 
 		conn.SetReadDeadline(time.Now().Add(READER_MAXWAIT))
@@ -129,178 +129,64 @@ func handleConnection(conn net.Conn) {
 
 		reqs++
 
+		if queued != nil {
+			select {
+			case m := <-queued.matched:
+				room, color, queued = m.room, m.color, nil
+			default:
+			}
+		}
+
 		switch v := msg.(type) {
 		case *chesspb.Ping:
 			continue
+		case *chesspb.ListGames:
+			Rooms.Subscribe(c)
+		case *chesspb.CreateChallenge:
+			if seat() {
+				room, color = Rooms.CreateChallenge(c, v)
+			}
+		case *chesspb.AcceptChallenge:
+			if seat() {
+				room, color = Rooms.Accept(c, v.Game)
+			}
+		case *chesspb.Queue:
+			if seat() {
+				queued = Matches.Enqueue(c, v)
+			}
+		case *chesspb.LeaveQueue:
+			unqueue()
 		case *chesspb.Join:
+			if !seat() {
+				continue
+			}
 			if v.Player {
-				if playern >= 0 {
-					c.Send(&chesspb.Error{Msg: "You're already a player."})
-					continue
-				}
-				if atomic.CompareAndSwapInt32(players, 0, 1) {
-					playern = 0
-					if rand.Intn(2) == 0 { // Randomly assign who is white or black
-						WhiteClient = c
-						color = White
-					} else {
-						BlackClient = c
-						color = Black
-					}
-					c.Send(&chesspb.Player{One: true})
-				} else if atomic.CompareAndSwapInt32(players, 1, 2) {
-					playern = 1
-					if WhiteClient == nil {
-						WhiteClient = c
-						color = White
-						BlackClient.Send(new(chesspb.OpponentJoined))
-					} else {
-						BlackClient = c
-						color = Black
-						WhiteClient.Send(new(chesspb.OpponentJoined))
-					}
-					c.Send(&chesspb.Player{One: false})
-				} else {
-					c.Send(&chesspb.Error{Msg: "All player slots filled."})
-				}
+				room, color = Rooms.Join(c, v.Game, v.Create)
 			} else {
-				playern = 3 // spectator
-				ObserverClient = c
+				room = Rooms.Watch(c, v.Game)
 			}
 		case *chesspb.NewGame:
-			if playern == 0 {
-				if BlackClient == nil || WhiteClient == nil {
-					c.Send(&chesspb.Error{Msg: "Need 2 players to play."})
-					continue
-				}
-				if !GameRunning {
-					// TODO announce new game to spectators
-					GameRunning = true
-					NeedPromotion = None
-					Game = chess.NewChessboard()
-					BlackClient.Send(&chesspb.Team{Black: true})
-					WhiteClient.Send(&chesspb.Team{Black: false})
-					if ObserverClient != nil {
-						ObserverClient.Send(new(chesspb.Team))
-					}
-				} else {
-					c.Send(&chesspb.Error{Msg: "Game already started."})
-				}
-			} else {
-				c.Send(&chesspb.Error{Msg: "Only player 1 can start a game."})
+			if room == nil {
+				c.Send(&chesspb.Error{Msg: "You haven't joined a game."})
+				continue
 			}
+			room.NewGame(c, v)
 		case *chesspb.Promote:
-			if !GameRunning {
+			if room == nil {
 				c.Send(&chesspb.Error{Msg: "Game has not started."})
 				continue
 			}
-			if NeedPromotion != color {
-				c.Send(&chesspb.Error{Msg: "You're not ready for a promotion yet."})
-				continue
-			}
-			if !Game.PromotePawn(int8(v.X), int8(v.Y), chess.Piece(v.To)) {
-				c.Send(&chesspb.Error{Msg: "Invalid selection."})
-				continue
-			}
-			NeedPromotion = None
-			WhiteClient.Send(v)
-			BlackClient.Send(v)
-			if ObserverClient != nil {
-				ObserverClient.Send(v)
-			}
+			room.Promote(c, color, v)
 		case *chesspb.Move:
-			if !GameRunning {
+			if room == nil {
 				c.Send(&chesspb.Error{Msg: "Game has not started."})
 				continue
 			}
-			if NeedPromotion != None {
-				if NeedPromotion == Black {
-					c.Send(&chesspb.Error{Msg: "Waiting on black to pick a promotion."})
-					continue
-				} else {
-					c.Send(&chesspb.Error{Msg: "Waiting on white to pick a promotion."})
-					continue
-				}
-			}
-			if playern == 3 || (color != Black && BlackMove) || (color != White && !BlackMove) {
-				c.Send(&chesspb.Error{Msg: "It's not your turn."})
-				continue
-			}
-			if Game.Board[int(v.Fy)][int(v.Fx)] == 0 {
-				c.Send(&chesspb.Error{Msg: "There's no piece there."})
-				continue
-			}
-			if (!chess.IsBlack(Game.Board[int(v.Fy)][int(v.Fx)]) && color == Black) || (chess.IsBlack(Game.Board[int(v.Fy)][int(v.Fx)]) && color == White) {
-				c.Send(&chesspb.Error{Msg: "That's not your piece."})
-				continue
-			}
-			if !Game.IsLegal([2]int8{int8(v.Fx), int8(v.Fy)}, [2]int8{int8(v.Tx), int8(v.Ty)}, chess.MoveType(v.MoveType)) {
-				c.Send(&chesspb.Error{Msg: "That's not legal."})
-				continue
-			}
-			switch chess.MoveType(v.MoveType) {
-			case chess.RegularMove:
-				Game.DoMove([2]int8{int8(v.Fx), int8(v.Fy)}, [2]int8{int8(v.Tx), int8(v.Ty)})
-				if (v.Ty == 7 || v.Ty == 0) && (Game.Board[v.Ty][v.Tx] == chess.WhitePawn || Game.Board[v.Ty][v.Tx] == chess.BlackPawn) {
-					black := chess.IsBlack(Game.Board[v.Ty][v.Tx])
-					if black {
-						NeedPromotion = Black
-					} else {
-						NeedPromotion = White
-					}
-					if black {
-						BlackClient.Send(&chesspb.Promote{X: v.Tx, Y: v.Ty})
-					} else {
-						WhiteClient.Send(&chesspb.Promote{X: v.Tx, Y: v.Ty})
-					}
-				}
-			case chess.EnPassant:
-				Game.DoEnPassant([2]int8{int8(v.Fx), int8(v.Fy)}, [2]int8{int8(v.Tx), int8(v.Ty)})
-			case chess.CastleLeft:
-				Game.DoCastle([2]int8{int8(v.Fx), int8(v.Fy)}, true)
-			case chess.CastleRight:
-				Game.DoCastle([2]int8{int8(v.Fx), int8(v.Fy)}, false)
-			}
-
-			WhiteClient.Send(v)
-			BlackClient.Send(v)
-			if ObserverClient != nil {
-				ObserverClient.Send(v)
-			}
-			BlackMove = !BlackMove
-
-			blackCheckmate := Game.IsCheckmated(true)
-			whiteCheckmate := Game.IsCheckmated(false)
-			if blackCheckmate || whiteCheckmate {
-				result := new(chesspb.GameComplete)
-
-				if blackCheckmate && !whiteCheckmate {
-					result.Result = chesspb.GameComplete_WhiteWin
-				} else if whiteCheckmate && !blackCheckmate {
-					result.Result = chesspb.GameComplete_BlackWin
-				}
-				WhiteClient.Send(result)
-				BlackClient.Send(result)
-				if ObserverClient != nil {
-					ObserverClient.Send(result)
-				}
-				playern = -1
-				atomic.StoreInt32(players, 0)
-				GameRunning = false
-				BlackMove = false
-				WhiteClient.Send(new(chesspb.Ping))
-				BlackClient.Send(new(chesspb.Ping))
-				WhiteClient = nil
-				BlackClient = nil
-			}
+			room.Move(c, color, v)
 		}
 	}
 }
 
-func init() {
-	players = new(int32)
-}
-
 func main() {
 	rand.Seed(time.Now().UnixNano())
 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -317,6 +203,7 @@ func main() {
 	})
 
 	go keepAlive()
+	go Matches.Run()
 	err := http.ListenAndServe(":8181", fn)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,162 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TheDiscordian/speedychess/chesspb"
+)
+
+const (
+	QUEUE_RATING      = 1500            //rating of players who don't give one
+	QUEUE_WINDOW      = 100             //rating difference allowed when a player joins the queue
+	QUEUE_WIDEN       = 50              //how much the window widens every QUEUE_WIDEN_EVERY
+	QUEUE_WIDEN_EVERY = 5 * time.Second //how long a player waits before their window widens
+	QUEUE_MAXWINDOW   = 1000            //widest the window gets
+	QUEUE_INTERVAL    = 1 * time.Second //how often the queue is checked for new pairings
+)
+
+// match is the game a queued player was matched into.
+type match struct {
+	room  *Room
+	color Color
+}
+
+// seeker is a player waiting in the matchmaking queue.
+type seeker struct {
+	c               *chesspb.Client
+	rating          uint32
+	variant         chesspb.Variant
+	chess960        bool
+	base, increment uint32 // time control
+	kind            chesspb.TimeControl_Kind
+	since           time.Time // when the player joined the queue
+
+	matched chan match // receives the game once the player is matched
+}
+
+// window returns how far the seeker's opponent's rating may be from theirs.
+func (s *seeker) window(now time.Time) uint32 {
+	window := QUEUE_WINDOW + QUEUE_WIDEN*uint32(now.Sub(s.since)/QUEUE_WIDEN_EVERY)
+	if window > QUEUE_MAXWINDOW {
+		return QUEUE_MAXWINDOW
+	}
+	return window
+}
+
+// ratingDiff returns how far apart two ratings are.
+func ratingDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// accepts returns true if s and o want the same game, and each is within the other's rating window.
+func (s *seeker) accepts(o *seeker, now time.Time) bool {
+	if s.variant != o.variant || s.chess960 != o.chess960 || s.base != o.base || s.increment != o.increment ||
+		s.kind != o.kind {
+		return false
+	}
+	diff := ratingDiff(s.rating, o.rating)
+	return diff <= s.window(now) && diff <= o.window(now)
+}
+
+// Matchmaker pairs players waiting in its queue, and starts their games.
+type Matchmaker struct {
+	mu    sync.Mutex
+	queue []*seeker // oldest first
+	rooms *Registry
+}
+
+// NewMatchmaker returns a matchmaker starting games in rooms.
+func NewMatchmaker(rooms *Registry) *Matchmaker {
+	return &Matchmaker{rooms: rooms}
+}
+
+// Enqueue adds c to the queue, and pairs it straight away if it can. The game it's matched into is sent on the
+// returned seeker's matched channel.
+func (mm *Matchmaker) Enqueue(c *chesspb.Client, v *chesspb.Queue) *seeker {
+	s := &seeker{
+		c:         c,
+		rating:    v.Rating,
+		variant:   v.Variant,
+		chess960:  v.Chess960,
+		base:      v.TimeControl.GetBase(),
+		increment: v.TimeControl.GetIncrement(),
+		kind:      v.TimeControl.GetKind(),
+		since:     time.Now(),
+		matched:   make(chan match, 1),
+	}
+	if s.rating == 0 {
+		s.rating = QUEUE_RATING
+	}
+	mm.mu.Lock()
+	mm.queue = append(mm.queue, s)
+	mm.mu.Unlock()
+	mm.Pair(s.since)
+	return s
+}
+
+// Dequeue removes s from the queue, and returns true. Returns false if s was already matched, in which case the game
+// is (or is about to be) on its matched channel.
+func (mm *Matchmaker) Dequeue(s *seeker) bool {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	for i, q := range mm.queue {
+		if q == s {
+			mm.queue = append(mm.queue[:i], mm.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Pair matches everyone in the queue it can as of now, oldest first, each with the closest rated player who accepts
+// them, and starts their games.
+func (mm *Matchmaker) Pair(now time.Time) {
+	var pairs [][2]*seeker
+	mm.mu.Lock()
+	for i := 0; i < len(mm.queue); i++ {
+		s, best := mm.queue[i], -1
+		var bestDiff uint32
+		for j := i + 1; j < len(mm.queue); j++ {
+			o := mm.queue[j]
+			if !s.accepts(o, now) {
+				continue
+			}
+			if diff := ratingDiff(s.rating, o.rating); best < 0 || diff < bestDiff {
+				best, bestDiff = j, diff
+			}
+		}
+		if best >= 0 {
+			pairs = append(pairs, [2]*seeker{s, mm.queue[best]})
+			mm.queue = append(mm.queue[:best], mm.queue[best+1:]...)
+			mm.queue = append(mm.queue[:i], mm.queue[i+1:]...)
+			i--
+		}
+	}
+	mm.mu.Unlock()
+
+	for _, p := range pairs {
+		one, two := p[0], p[1]
+		r := mm.rooms.Create(&chesspb.Challenge{
+			Variant:     one.variant,
+			Chess960:    one.chess960,
+			TimeControl: &chesspb.TimeControl{Base: one.base, Increment: one.increment, Kind: one.kind},
+		})
+		oneColor, twoColor := r.Pair(one.c, two.c, one.rating, two.rating)
+		one.matched <- match{r, oneColor}
+		two.matched <- match{r, twoColor}
+	}
+}
+
+// Run pairs the queue every QUEUE_INTERVAL, as the rating windows widen.
+func (mm *Matchmaker) Run() {
+	for {
+		time.Sleep(QUEUE_INTERVAL)
+		mm.Pair(time.Now())
+	}
+}
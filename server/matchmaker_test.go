@@ -0,0 +1,78 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheDiscordian/speedychess/chesspb"
+)
+
+func TestMatchmaker(t *testing.T) {
+	mm := NewMatchmaker(NewRegistry())
+	blitz := &chesspb.TimeControl{Base: 300, Increment: 3}
+	a, b, c := newClient(), newClient(), newClient()
+
+	sa := mm.Enqueue(a, &chesspb.Queue{Rating: 1500, TimeControl: blitz})
+	sb := mm.Enqueue(b, &chesspb.Queue{Rating: 1500, TimeControl: &chesspb.TimeControl{Base: 60}})
+	sc := mm.Enqueue(c, &chesspb.Queue{Rating: 1800, TimeControl: blitz})
+	if len(mm.queue) != 3 {
+		t.Fatalf("paired players with different time controls or far apart ratings, queue is %d long", len(mm.queue))
+	}
+
+	// c joined a second after a, so its window is still 250 wide 20 seconds after a joined
+	sc.since = sa.since.Add(time.Second)
+	mm.Pair(sa.since.Add(20 * time.Second))
+	if len(mm.queue) != 3 {
+		t.Fatalf("paired players before their windows widened far enough")
+	}
+	mm.Pair(sa.since.Add(25 * time.Second))
+	if len(mm.queue) != 1 || mm.queue[0] != sb {
+		t.Fatalf("didn't pair players once their windows widened")
+	}
+	ma, mc := <-sa.matched, <-sc.matched
+	if ma.room != mc.room || ma.color == mc.color || ma.color == None || mc.color == None {
+		t.Fatalf("matched into rooms %d and %d as %v and %v", ma.room.ID, mc.room.ID, ma.color, mc.color)
+	}
+	msgs := received(t, a)
+	if len(msgs) < 2 {
+		t.Fatalf("player received %v", msgs)
+	}
+	found, ok := msgs[0].(*chesspb.MatchFound)
+	if !ok || found.Game != ma.room.ID || found.OpponentRating != 1800 || found.TimeControl.Base != 300 {
+		t.Errorf("player was sent %v before the game started", msgs[0])
+	}
+	if _, ok := msgs[1].(*chesspb.Team); !ok {
+		t.Errorf("game didn't start, player received %v", msgs)
+	}
+
+	if !mm.Dequeue(sb) || len(mm.queue) != 0 {
+		t.Errorf("couldn't leave the queue")
+	}
+	if mm.Dequeue(sa) {
+		t.Errorf("left the queue after being matched")
+	}
+}
+
+func TestMatchmakerClosest(t *testing.T) {
+	mm := NewMatchmaker(NewRegistry())
+	a, far, near := newClient(), newClient(), newClient()
+	sa := mm.Enqueue(a, &chesspb.Queue{Rating: 1600})
+	sFar := mm.Enqueue(far, &chesspb.Queue{Rating: 1800})
+	sNear := mm.Enqueue(near, &chesspb.Queue{Rating: 1650})
+	select {
+	case m := <-sNear.matched:
+		if (<-sa.matched).room != m.room {
+			t.Errorf("paired with different rooms")
+		}
+	default:
+		t.Fatalf("didn't pair the closest ratings")
+	}
+	if len(mm.queue) != 1 || mm.queue[0] != sFar {
+		t.Errorf("queue should only have the furthest rated player")
+	}
+	if mm.Enqueue(newClient(), &chesspb.Queue{}).rating != QUEUE_RATING {
+		t.Errorf("players without a rating should get QUEUE_RATING")
+	}
+}
@@ -0,0 +1,596 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheDiscordian/speedychess/chess"
+	"github.com/TheDiscordian/speedychess/chesspb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Room is a game on the server, with its own players, spectators and position. Each client's goroutine calls its
+// methods, which are safe to use concurrently. A room closes once its game ends or a player leaves, and the players
+// need to join another. Spectators stay until they leave, and the room is removed once it's closed and empty.
+type Room struct {
+	ID uint32
+
+	mu            sync.Mutex
+	game          *chess.Game
+	variant       chesspb.Variant
+	timeControl   *chesspb.TimeControl // nil if the game has no clock
+	running       bool
+	host          *chesspb.Client    // player 1, who starts the game
+	players       [2]*chesspb.Client // white then black
+	spectators    []*chesspb.Client
+	needPromotion Color // represents a colour that needs to promote a pawn for the game to continue
+	closed        bool
+	challenge     *chesspb.Challenge // what the room was created to play, nil if player 1 starts the game
+	clock         *Clock             // nil if timeControl is
+	timer         *time.Timer        // ends the game when the running clock's flag falls
+}
+
+// Closed returns true once the room's game has ended or a player has left.
+func (r *Room) Closed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// Waiting returns true if the room has one player, and is waiting for another.
+func (r *Room) Waiting() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.waiting()
+}
+
+func (r *Room) waiting() bool {
+	return !r.closed && (r.players[White] == nil) != (r.players[Black] == nil)
+}
+
+// Challenge returns the room's challenge if it's still open, otherwise nil.
+func (r *Room) Challenge() *chesspb.Challenge {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.waiting() {
+		return nil
+	}
+	return r.challenge
+}
+
+// Playing returns true if the room's game has started, and not yet ended.
+func (r *Room) Playing() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+// clients returns everyone in the room.
+func (r *Room) clients() []*chesspb.Client {
+	clients := append([]*chesspb.Client(nil), r.spectators...)
+	for _, c := range r.players {
+		if c != nil {
+			clients = append(clients, c)
+		}
+	}
+	return clients
+}
+
+// broadcast sends a message to everyone in the room.
+func (r *Room) broadcast(msg proto.Message) {
+	for _, c := range r.clients() {
+		c.Send(msg)
+	}
+}
+
+// Ping pings everyone in the room to keep them connected, and returns false once the room is closed and empty.
+func (r *Room) Ping() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.broadcast(new(chesspb.Ping))
+	return !r.closed || len(r.spectators) > 0
+}
+
+// Join adds c to the room as a player, and returns its colour. Returns None if the room is full or closed. The game
+// starts as soon as a challenge's opponent joins.
+func (r *Room) Join(c *chesspb.Client) Color {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		c.Send(&chesspb.Error{Msg: "That game has ended."})
+		return None
+	}
+	var color Color
+	switch {
+	case r.players[White] == nil && r.players[Black] == nil:
+		color = Color(rand.Intn(2)) // Randomly assign who is white or black
+		r.players[color] = c
+		r.host = c
+		c.Send(&chesspb.Player{One: true, Game: r.ID})
+		return color
+	case r.players[White] == nil:
+		color = White
+	case r.players[Black] == nil:
+		color = Black
+	default:
+		c.Send(&chesspb.Error{Msg: "All player slots filled."})
+		return None
+	}
+	r.players[color] = c
+	c.Send(&chesspb.Player{One: false, Game: r.ID})
+	if r.challenge != nil {
+		r.start(r.challenge.Chess960, r.challenge.Variant, r.challenge.TimeControl)
+	} else {
+		r.host.Send(new(chesspb.OpponentJoined))
+	}
+	return color
+}
+
+// Pair seats two players matched by the matchmaker in the empty room, tells each who they're playing, and starts the
+// room's challenge. Returns their colours.
+func (r *Room) Pair(one, two *chesspb.Client, oneRating, twoRating uint32) (Color, Color) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	color := Color(rand.Intn(2)) // Randomly assign who is white or black
+	r.players[color], r.players[1-color] = one, two
+	r.host = one
+	for _, p := range []struct {
+		c      *chesspb.Client
+		rating uint32
+	}{{one, twoRating}, {two, oneRating}} {
+		p.c.Send(&chesspb.MatchFound{
+			Game:           r.ID,
+			OpponentRating: p.rating,
+			Variant:        r.challenge.Variant,
+			Chess960:       r.challenge.Chess960,
+			TimeControl:    r.challenge.TimeControl,
+		})
+	}
+	r.start(r.challenge.Chess960, r.challenge.Variant, r.challenge.TimeControl)
+	return color, 1 - color
+}
+
+// Watch adds c to the room as a spectator.
+func (r *Room) Watch(c *chesspb.Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		c.Send(&chesspb.Error{Msg: "That game has ended."})
+		return false
+	}
+	r.spectators = append(r.spectators, c)
+	if r.running {
+		// the spectator starts from the current position, with the time left on each clock
+		team := &chesspb.Team{Fen: r.game.Chessboard.FEN(), Variant: r.variant, TimeControl: r.timeControl}
+		if r.clock != nil {
+			team.WhiteTime, team.BlackTime = r.clock.Millis(time.Now())
+		}
+		c.Send(team)
+	}
+	return true
+}
+
+// Leave removes c from the room, which closes it if c is a player.
+func (r *Room) Leave(c *chesspb.Client, color Color) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if color == None {
+		for i, s := range r.spectators {
+			if s == c {
+				r.spectators = append(r.spectators[:i], r.spectators[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+	if r.players[1-color] != nil {
+		r.players[1-color].Send(new(chesspb.OpponentLeft))
+	}
+	r.close()
+}
+
+// close stops the game, and frees up the player slots.
+func (r *Room) close() {
+	r.closed = true
+	r.running = false
+	r.players = [2]*chesspb.Client{}
+	r.host = nil
+	r.needPromotion = None
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// NewGame starts a game, if c is player 1.
+func (r *Room) NewGame(c *chesspb.Client, v *chesspb.NewGame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c != r.host {
+		c.Send(&chesspb.Error{Msg: "Only player 1 can start a game."})
+		return
+	}
+	if r.players[White] == nil || r.players[Black] == nil {
+		c.Send(&chesspb.Error{Msg: "Need 2 players to play."})
+		return
+	}
+	if r.running {
+		c.Send(&chesspb.Error{Msg: "Game already started."})
+		return
+	}
+	r.start(v.Chess960, v.Variant, v.TimeControl)
+}
+
+// start starts a game between the room's players, with a clock if tc has one.
+func (r *Room) start(chess960 bool, variant chesspb.Variant, tc *chesspb.TimeControl) {
+	r.running = true
+	r.needPromotion = None
+	var (
+		board *chess.Chessboard
+		fen   string
+	)
+	if chess960 {
+		board, _ = chess.NewChess960(rand.Intn(960))
+		fen = board.FEN()
+	} else {
+		board = chess.NewChessboard()
+	}
+	r.variant = variant
+	board.Variant = r.variant.Chess()
+	r.game = chess.NewGame(board)
+	r.clock = NewClock(tc, time.Now())
+	if r.clock == nil {
+		tc = nil
+	}
+	r.timeControl = tc
+	r.players[Black].Send(&chesspb.Team{Black: true, Fen: fen, Variant: r.variant, TimeControl: tc})
+	r.players[White].Send(&chesspb.Team{Black: false, Fen: fen, Variant: r.variant, TimeControl: tc})
+	for _, s := range r.spectators {
+		s.Send(&chesspb.Team{Fen: fen, Variant: r.variant, TimeControl: tc})
+	}
+	r.schedule()
+}
+
+// schedule sets the timer to end the game when the running clock's flag falls.
+func (r *Room) schedule() {
+	if r.clock == nil {
+		return
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(r.clock.Until(time.Now()), r.checkFlag)
+}
+
+// checkFlag ends the game if a player has run out of time.
+func (r *Room) checkFlag() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		r.flagged(time.Now())
+	}
+}
+
+// flagged ends the game and returns true if a player had run out of time as of now.
+func (r *Room) flagged(now time.Time) bool {
+	if r.clock == nil {
+		return false
+	}
+	color := r.clock.Flag(now)
+	if color == None {
+		return false
+	}
+	r.clock.Stop(now)
+	r.game.Timeout(color == Black)
+	r.finish()
+	return true
+}
+
+// press ends the turn on the clock, and sets the time left in the message announcing the move.
+func (r *Room) press(now time.Time, whiteTime, blackTime *uint32) {
+	if r.clock == nil {
+		return
+	}
+	r.clock.Press(now)
+	*whiteTime, *blackTime = r.clock.Millis(now)
+	r.schedule()
+}
+
+// Promote promotes the pawn of color waiting to be promoted.
+func (r *Room) Promote(c *chesspb.Client, color Color, v *chesspb.Promote) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.running {
+		c.Send(&chesspb.Error{Msg: "Game has not started."})
+		return
+	}
+	now := time.Now()
+	if r.flagged(now) {
+		return
+	}
+	if color == None || r.needPromotion != color {
+		c.Send(&chesspb.Error{Msg: "You're not ready for a promotion yet."})
+		return
+	}
+	if s := v.Square(); !s.Valid() || !r.game.PromotePawn(s.X(), s.Y(), chess.Piece(v.To)) {
+		c.Send(&chesspb.Error{Msg: "Invalid selection."})
+		return
+	}
+	r.needPromotion = None
+	r.press(now, &v.WhiteTime, &v.BlackTime)
+	r.broadcast(v)
+	r.finish()
+}
+
+// Move plays a move by color.
+func (r *Room) Move(c *chesspb.Client, color Color, v *chesspb.Move) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.running {
+		c.Send(&chesspb.Error{Msg: "Game has not started."})
+		return
+	}
+	now := time.Now()
+	if r.flagged(now) {
+		return
+	}
+	if r.needPromotion != None {
+		if r.needPromotion == Black {
+			c.Send(&chesspb.Error{Msg: "Waiting on black to pick a promotion."})
+		} else {
+			c.Send(&chesspb.Error{Msg: "Waiting on white to pick a promotion."})
+		}
+		return
+	}
+	game := r.game
+	if color == None || (color != Black && game.BlackMove) || (color != White && !game.BlackMove) {
+		c.Send(&chesspb.Error{Msg: "It's not your turn."})
+		return
+	}
+	from, to := v.From(), v.To()
+	if !to.Valid() || (!from.Valid() && chess.MoveType(v.MoveType) != chess.Drop) {
+		c.Send(&chesspb.Error{Msg: "That's not on the board."})
+		return
+	}
+	if chess.MoveType(v.MoveType) == chess.Drop {
+		if !game.CanDrop(chess.Piece(v.Piece), to.Coords()) {
+			c.Send(&chesspb.Error{Msg: "That's not legal."})
+			return
+		}
+	} else if piece := game.Board[from.Y()][from.X()]; piece == 0 {
+		c.Send(&chesspb.Error{Msg: "There's no piece there."})
+		return
+	} else if (!chess.IsBlack(piece) && color == Black) || (chess.IsBlack(piece) && color == White) {
+		c.Send(&chesspb.Error{Msg: "That's not your piece."})
+		return
+	} else if !game.IsLegal(from.Coords(), to.Coords(), chess.MoveType(v.MoveType)) {
+		c.Send(&chesspb.Error{Msg: "That's not legal."})
+		return
+	}
+	switch chess.MoveType(v.MoveType) {
+	case chess.RegularMove:
+		game.DoMove(from.Coords(), to.Coords())
+		if piece := game.Board[to.Y()][to.X()]; (to.Rank() == 1 || to.Rank() == 8) && (piece == chess.WhitePawn || piece == chess.BlackPawn) {
+			if chess.IsBlack(piece) {
+				r.needPromotion = Black
+			} else {
+				r.needPromotion = White
+			}
+			r.players[r.needPromotion].Send(&chesspb.Promote{X: v.Tx, Y: v.Ty})
+		}
+	case chess.EnPassant:
+		game.DoEnPassant(from.Coords(), to.Coords())
+	case chess.CastleLeft:
+		game.DoCastle(from.Coords(), true)
+	case chess.CastleRight:
+		game.DoCastle(from.Coords(), false)
+	case chess.Drop:
+		game.DoDrop(chess.Piece(v.Piece), to.Coords())
+	}
+
+	if r.needPromotion == None {
+		r.press(now, &v.WhiteTime, &v.BlackTime)
+	} else if r.clock != nil {
+		v.WhiteTime, v.BlackTime = r.clock.Millis(now)
+	}
+	r.broadcast(v)
+	// draws which could be claimed are claimed automatically
+	game.ClaimDraw()
+	r.finish()
+}
+
+// finish announces the result if the game has ended, and closes the room.
+func (r *Room) finish() {
+	result := chesspb.NewGameComplete(r.game)
+	if result == nil {
+		return
+	}
+	r.broadcast(result)
+	for _, c := range r.players {
+		c.Send(new(chesspb.Ping))
+	}
+	r.close()
+}
+
+// Registry keeps every room on the server by ID, and the lobby of clients following the open challenges.
+type Registry struct {
+	mu    sync.Mutex
+	rooms map[uint32]*Room
+	last  uint32 // ID of the last room created
+	lobby map[*chesspb.Client]bool
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{rooms: make(map[uint32]*Room), lobby: make(map[*chesspb.Client]bool)}
+}
+
+// Create adds a new room, to play challenge if it isn't nil.
+func (reg *Registry) Create(challenge *chesspb.Challenge) *Room {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.last++
+	r := &Room{ID: reg.last, needPromotion: None, challenge: challenge}
+	if challenge != nil {
+		challenge.Game = r.ID
+	}
+	reg.rooms[r.ID] = r
+	return r
+}
+
+// Get returns the room with the ID, or nil if there isn't one.
+func (reg *Registry) Get(id uint32) *Room {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.rooms[id]
+}
+
+// Remove removes a room.
+func (reg *Registry) Remove(id uint32) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.rooms, id)
+}
+
+// Rooms returns every room, oldest first.
+func (reg *Registry) Rooms() []*Room {
+	reg.mu.Lock()
+	rooms := make([]*Room, 0, len(reg.rooms))
+	for _, r := range reg.rooms {
+		rooms = append(rooms, r)
+	}
+	reg.mu.Unlock()
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	return rooms
+}
+
+// List returns the open challenges, and the games being played.
+func (reg *Registry) List() *chesspb.ChallengeList {
+	list := new(chesspb.ChallengeList)
+	for _, r := range reg.Rooms() {
+		if challenge := r.Challenge(); challenge != nil {
+			list.Challenges = append(list.Challenges, challenge)
+		} else if r.Playing() {
+			list.Games = append(list.Games, r.ID)
+		}
+	}
+	return list
+}
+
+// Subscribe adds c to the lobby, and sends it the list of challenges. It's sent again whenever a challenge is
+// created, accepted or withdrawn.
+func (reg *Registry) Subscribe(c *chesspb.Client) {
+	reg.mu.Lock()
+	reg.lobby[c] = true
+	reg.mu.Unlock()
+	c.Send(reg.List())
+}
+
+// Unsubscribe removes c from the lobby.
+func (reg *Registry) Unsubscribe(c *chesspb.Client) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.lobby, c)
+}
+
+// announce sends the list of challenges to the lobby.
+func (reg *Registry) announce() {
+	list := reg.List()
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for c := range reg.lobby {
+		c.Send(list)
+	}
+}
+
+// seat adds c as a player to r, announcing the challenges if it accepted one.
+func (reg *Registry) seat(c *chesspb.Client, r *Room) Color {
+	open := r.Challenge() != nil
+	color := r.Join(c)
+	if open && color != None {
+		reg.announce()
+	}
+	return color
+}
+
+// Join adds c as a player to the room with the ID, or if create is true, a new room where c starts the game once
+// someone joins. Returns the room and c's colour, or nil and None if c couldn't join.
+func (reg *Registry) Join(c *chesspb.Client, id uint32, create bool) (*Room, Color) {
+	if id == 0 {
+		if !create {
+			c.Send(&chesspb.Error{Msg: "Create or accept a challenge to play, or join a game by its ID."})
+			return nil, None
+		}
+		r := reg.Create(nil)
+		return r, r.Join(c)
+	}
+	r := reg.Get(id)
+	if r == nil {
+		c.Send(&chesspb.Error{Msg: "There's no game with that ID."})
+		return nil, None
+	}
+	color := reg.seat(c, r)
+	if color == None {
+		return nil, None
+	}
+	return r, color
+}
+
+// CreateChallenge adds a room for the challenge, with c as player 1, and announces it to the lobby.
+func (reg *Registry) CreateChallenge(c *chesspb.Client, v *chesspb.CreateChallenge) (*Room, Color) {
+	r := reg.Create(&chesspb.Challenge{Variant: v.Variant, Chess960: v.Chess960, TimeControl: v.TimeControl})
+	color := r.Join(c)
+	reg.announce()
+	return r, color
+}
+
+// Accept adds c as the opponent in the challenge's room, which starts the game. Returns nil and None if the challenge
+// isn't open.
+func (reg *Registry) Accept(c *chesspb.Client, id uint32) (*Room, Color) {
+	r := reg.Get(id)
+	if r == nil || r.Challenge() == nil {
+		c.Send(&chesspb.Error{Msg: "That challenge is no longer open."})
+		return nil, None
+	}
+	color := reg.seat(c, r)
+	if color == None {
+		return nil, None
+	}
+	return r, color
+}
+
+// Leave removes c from r, announcing the challenges if it withdrew one.
+func (reg *Registry) Leave(c *chesspb.Client, r *Room, color Color) {
+	open := r.Challenge() != nil
+	r.Leave(c, color)
+	if open && r.Challenge() == nil {
+		reg.announce()
+	}
+}
+
+// Watch adds c as a spectator to the room with the ID, or if id is 0, the newest room still open. Returns nil if
+// there's no such room.
+func (reg *Registry) Watch(c *chesspb.Client, id uint32) *Room {
+	var r *Room
+	if id != 0 {
+		r = reg.Get(id)
+	} else {
+		rooms := reg.Rooms()
+		for i := len(rooms) - 1; i >= 0 && r == nil; i-- {
+			if !rooms[i].Closed() {
+				r = rooms[i]
+			}
+		}
+	}
+	if r == nil {
+		c.Send(&chesspb.Error{Msg: "There's no game to watch."})
+		return nil
+	}
+	if !r.Watch(c) {
+		return nil
+	}
+	return r
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2020, The SpeedyChess Contributors. All rights reserved.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/TheDiscordian/speedychess/chess"
+	"github.com/TheDiscordian/speedychess/chesspb"
+	"google.golang.org/protobuf/proto"
+)
+
+func newClient() *chesspb.Client {
+	return &chesspb.Client{W: make(chan []byte, 64)}
+}
+
+// received returns the messages sent to c since it was last checked.
+func received(t *testing.T, c *chesspb.Client) (msgs []proto.Message) {
+	t.Helper()
+	for {
+		select {
+		case data := <-c.W:
+			var m proto.Message
+			if err := chesspb.ReadMessage(bufio.NewReader(bytes.NewReader(data)), &m); err != nil {
+				t.Fatal(err)
+			}
+			msgs = append(msgs, m)
+		default:
+			return
+		}
+	}
+}
+
+// has returns true if msgs has a message of the same type as m.
+func has(msgs []proto.Message, m proto.Message) bool {
+	for _, msg := range msgs {
+		if msg.ProtoReflect().Descriptor() == m.ProtoReflect().Descriptor() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRooms(t *testing.T) {
+	reg := NewRegistry()
+	a, b, c, d, e := newClient(), newClient(), newClient(), newClient(), newClient()
+
+	if r, color := reg.Join(a, 0, false); color != None {
+		t.Fatalf("joined room %d without creating or accepting a challenge", r.ID)
+	}
+	received(t, a)
+	roomA, colorA := reg.Join(a, 0, true)
+	roomB, colorB := reg.Join(b, roomA.ID, false)
+	if roomA != roomB || colorA == None || colorB == None || colorA == colorB {
+		t.Fatalf("first two players should share a room with different colours")
+	}
+	roomC, _ := reg.Join(c, 0, true)
+	roomD, _ := reg.Join(d, roomC.ID, false)
+	if roomC == roomA || roomD != roomC {
+		t.Fatalf("third player should create a room, and the fourth join it")
+	}
+	if r := reg.Watch(e, roomA.ID); r != roomA {
+		t.Fatalf("Watch(%d) = %v", roomA.ID, r)
+	}
+	if msgs := received(t, a); !has(msgs, new(chesspb.Player)) || !has(msgs, new(chesspb.OpponentJoined)) {
+		t.Errorf("player 1 received %v", msgs)
+	}
+	if r, color := reg.Join(newClient(), roomA.ID, false); color != None {
+		t.Errorf("joined a full room %d as %v", r.ID, color)
+	}
+	for _, cl := range []*chesspb.Client{b, c, d} {
+		received(t, cl)
+	}
+
+	roomB.NewGame(b, new(chesspb.NewGame))
+	if !has(received(t, b), new(chesspb.Error)) {
+		t.Errorf("player 2 started a game")
+	}
+	roomA.NewGame(a, new(chesspb.NewGame))
+	roomC.NewGame(c, new(chesspb.NewGame))
+	white := a
+	if colorB == White {
+		white = b
+	}
+	move := chesspb.NewMove(chess.SquareAt(4, 6), chess.SquareAt(4, 4), chess.RegularMove) // e2e4
+	roomA.Move(white, White, move)
+	for _, cl := range []*chesspb.Client{a, b, e} {
+		if !has(received(t, cl), new(chesspb.Move)) {
+			t.Errorf("move wasn't sent to everyone in room %d", roomA.ID)
+		}
+	}
+	for _, cl := range []*chesspb.Client{c, d} {
+		if has(received(t, cl), new(chesspb.Move)) {
+			t.Errorf("move was sent to room %d", roomC.ID)
+		}
+	}
+	if roomC.game.Ply() != 0 {
+		t.Errorf("move was played in room %d", roomC.ID)
+	}
+
+	roomA.Leave(a, colorA)
+	if !has(received(t, b), new(chesspb.OpponentLeft)) || !roomA.Closed() {
+		t.Errorf("leaving didn't close room %d", roomA.ID)
+	}
+	if r := reg.Watch(newClient(), 0); r != roomC {
+		t.Errorf("Watch(0) = %v, want the open room %d", r, roomC.ID)
+	}
+	if !roomA.Ping() {
+		t.Errorf("room %d was removed with a spectator left", roomA.ID)
+	}
+	roomA.Leave(e, None)
+	if roomA.Ping() {
+		t.Errorf("room %d is closed and empty, but was kept", roomA.ID)
+	}
+}
+
+func TestChallenges(t *testing.T) {
+	reg := NewRegistry()
+	a, b, lobby := newClient(), newClient(), newClient()
+
+	reg.Subscribe(lobby)
+	if msgs := received(t, lobby); len(msgs) != 1 || len(msgs[0].(*chesspb.ChallengeList).Challenges) != 0 {
+		t.Fatalf("lobby received %v", msgs)
+	}
+	tc := &chesspb.TimeControl{Base: 300, Increment: 3}
+	room, _ := reg.CreateChallenge(a, &chesspb.CreateChallenge{Variant: chesspb.Variant_Crazyhouse, TimeControl: tc})
+	msgs := received(t, lobby)
+	if len(msgs) != 1 {
+		t.Fatalf("lobby received %v", msgs)
+	}
+	list := msgs[0].(*chesspb.ChallengeList).Challenges
+	if len(list) != 1 || list[0].Game != room.ID || list[0].Variant != chesspb.Variant_Crazyhouse || list[0].TimeControl.Base != 300 {
+		t.Fatalf("challenges = %v", list)
+	}
+	if got := list[0].Describe(); got != "Crazyhouse, 5+3" {
+		t.Errorf("Describe() = %q", got)
+	}
+
+	if _, color := reg.Accept(b, room.ID+1); color != None {
+		t.Errorf("accepted a challenge that doesn't exist")
+	}
+	received(t, b)
+	if r, color := reg.Accept(b, room.ID); r != room || color == None {
+		t.Fatalf("couldn't accept challenge %d", room.ID)
+	}
+	for _, c := range []*chesspb.Client{a, b} {
+		if msgs := received(t, c); !has(msgs, new(chesspb.Team)) {
+			t.Errorf("game didn't start, received %v", msgs)
+		}
+	}
+	msgs = received(t, lobby)
+	if len(msgs) != 1 {
+		t.Fatalf("lobby received %v", msgs)
+	}
+	if list := msgs[0].(*chesspb.ChallengeList); len(list.Challenges) != 0 || len(list.Games) != 1 || list.Games[0] != room.ID {
+		t.Errorf("list = %v after accepting", list)
+	}
+	if _, color := reg.Accept(newClient(), room.ID); color != None {
+		t.Errorf("accepted a challenge twice")
+	}
+
+	withdrawn, color := reg.CreateChallenge(a, new(chesspb.CreateChallenge))
+	received(t, lobby)
+	reg.Leave(a, withdrawn, color)
+	if msgs := received(t, lobby); len(msgs) != 1 || len(msgs[0].(*chesspb.ChallengeList).Challenges) != 0 {
+		t.Errorf("lobby received %v after withdrawing a challenge", msgs)
+	}
+	reg.Unsubscribe(lobby)
+	reg.CreateChallenge(b, new(chesspb.CreateChallenge))
+	if msgs := received(t, lobby); len(msgs) != 0 {
+		t.Errorf("lobby received %v after unsubscribing", msgs)
+	}
+}
+
+func TestWatchInProgress(t *testing.T) {
+	reg := NewRegistry()
+	a, b, e := newClient(), newClient(), newClient()
+	room := reg.Create(&chesspb.Challenge{TimeControl: &chesspb.TimeControl{Base: 60}})
+	colorA, _ := room.Pair(a, b, 1500, 1500)
+	players := [2]*chesspb.Client{a, b}
+	if colorA == Black {
+		players = [2]*chesspb.Client{b, a}
+	}
+	room.Move(players[White], White, chesspb.NewMove(chess.SquareAt(4, 6), chess.SquareAt(4, 4), chess.RegularMove))
+	room.Move(players[Black], Black, chesspb.NewMove(chess.SquareAt(4, 1), chess.SquareAt(4, 3), chess.RegularMove))
+	room.mu.Lock()
+	room.clock.started = room.clock.started.Add(-5 * time.Second) // white has been thinking for 5 seconds
+	room.mu.Unlock()
+
+	if r := reg.Watch(e, room.ID); r != room {
+		t.Fatalf("Watch(%d) = %v", room.ID, r)
+	}
+	msgs := received(t, e)
+	if len(msgs) != 1 {
+		t.Fatalf("spectator received %v, want its team", msgs)
+	}
+	team, ok := msgs[0].(*chesspb.Team)
+	if want := "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2"; !ok || team.Fen != want {
+		t.Fatalf("spectator received %v, want the position %s", msgs[0], want)
+	}
+	board, err := chess.ParseFEN(team.Fen)
+	if err != nil || board.FEN() != room.game.FEN() {
+		t.Errorf("spectator's board is %v, want %s (%v)", board, room.game.FEN(), err)
+	}
+	if team.WhiteTime > 55000 || team.WhiteTime < 54000 || team.BlackTime == 0 || team.BlackTime > 60000 {
+		t.Errorf("spectator received clocks %d, %d, want the time left", team.WhiteTime, team.BlackTime)
+	}
+}